@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// 路由文件中插入路由注册的位置
+const routesMarker = "// weber:routes"
+
+// weber gen：生成代码，目前只支持handler
+func runGen(args []string) error {
+	if len(args) == 0 || args[0] != "handler" {
+		fmt.Fprintln(os.Stderr, "usage: weber gen handler [-dir handlers] [-resource] <name>")
+		return USAGE_ERROR
+	}
+
+	flags := flag.NewFlagSet("gen handler", flag.ContinueOnError)
+	dir := flags.String("dir", "handlers", "package directory of the handler")
+	resource := flags.Bool("resource", false, "generate list/create/get/update/delete handlers")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: weber gen handler [-dir handlers] [-resource] <name>")
+		flags.PrintDefaults()
+	}
+	if err := parseArgs(flags, args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return USAGE_ERROR
+	}
+
+	n, err := parseName(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	module, err := modulePath("go.mod")
+	if err != nil {
+		return err
+	}
+
+	pkgDir := filepath.Clean(*dir)
+	data := struct {
+		names
+		Package string
+	}{names: n, Package: filepath.Base(pkgDir)}
+
+	kind := "handler"
+	if *resource {
+		kind = "resource"
+	}
+	target := filepath.Join(pkgDir, n.File)
+	for _, file := range []string{target + ".go", target + "_test.go"} {
+		if _, err := os.Stat(file); err == nil {
+			return fmt.Errorf("%s already exists", file)
+		}
+	}
+	if err := render("templates/gen/"+kind+".go.tmpl", target+".go", data); err != nil {
+		return err
+	}
+	if err := render("templates/gen/"+kind+"_test.go.tmpl", target+"_test.go", data); err != nil {
+		return err
+	}
+
+	importPath := module + "/" + filepath.ToSlash(pkgDir)
+	call := fmt.Sprintf("%s.Register%s(api)", data.Package, n.Ident)
+	if err := addRoute("routes.go", importPath, call); err != nil {
+		fmt.Fprintf(os.Stderr, "\n%v, register the routes manually:\n\n  import %q\n\n  %s\n", err, importPath, call)
+	}
+	return nil
+}
+
+// 读取go.mod中的模块路径
+func modulePath(gomod string) (string, error) {
+	data, err := os.ReadFile(gomod)
+	if err != nil {
+		return "", fmt.Errorf("%w, run weber gen in the project root", err)
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "module" {
+			return strings.Trim(fields[1], `"`), nil
+		}
+	}
+	return "", fmt.Errorf("no module path in %s", gomod)
+}
+
+// 在路由文件的标记之前插入路由注册，缺少导入时补上
+func addRoute(file string, importPath string, call string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	source := string(data)
+
+	idx := strings.Index(source, routesMarker)
+	if idx < 0 {
+		return fmt.Errorf("%s has no %s marker", file, routesMarker)
+	}
+	lineStart := strings.LastIndex(source[:idx], "\n") + 1
+	indent := source[lineStart:idx]
+	source = source[:lineStart] + indent + call + "\n" + source[lineStart:]
+
+	quoted := strconv.Quote(importPath)
+	if !strings.Contains(source, quoted) {
+		start := strings.Index(source, "import (")
+		if start < 0 {
+			return fmt.Errorf("%s has no import block", file)
+		}
+		start += len("import (")
+		source = source[:start] + "\n\t" + quoted + source[start:]
+	}
+
+	formatted, err := format.Source([]byte(source))
+	if err != nil {
+		return fmt.Errorf("format %s: %w", file, err)
+	}
+	if bytes.Equal(formatted, data) {
+		return nil
+	}
+	if err := os.WriteFile(file, formatted, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("  update", file)
+	return nil
+}
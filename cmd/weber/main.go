@@ -0,0 +1,82 @@
+// weber 命令行工具：生成项目骨架、生成处理函数、查看路由表
+//
+//	weber new [-module path] [-replace dir] [-skip-tidy] <dir>
+//	weber gen handler [-dir handlers] [-resource] <name>
+//	weber routes [-json] [dir]
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `weber 命令行工具
+
+用法:
+  weber new [-module path] [-replace dir] [-skip-tidy] <dir>   生成新项目
+  weber gen handler [-dir handlers] [-resource] <name>          生成处理函数、路由注册和测试
+  weber routes [-json] [dir]                                    以dry-run模式运行项目并输出路由表
+`
+
+// 参数错误，用法已输出，main以退出码2退出
+var USAGE_ERROR = errors.New("invalid usage")
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "new":
+		err = runNew(os.Args[2:])
+	case "gen":
+		err = runGen(os.Args[2:])
+	case "routes":
+		err = runRoutes(os.Args[2:])
+	case "help", "-h", "--help":
+		fmt.Print(usage)
+		return
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", os.Args[1], usage)
+		os.Exit(2)
+	}
+
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+	case errors.Is(err, USAGE_ERROR):
+		os.Exit(2)
+	default:
+		fmt.Fprintln(os.Stderr, "weber:", err)
+		os.Exit(1)
+	}
+}
+
+// 解析参数，允许选项写在位置参数之后，如 weber gen handler user -resource
+// flags需使用flag.ContinueOnError，解析失败时FlagSet已输出用法，返回USAGE_ERROR；-h时返回flag.ErrHelp
+func parseArgs(flags *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := parseFlags(flags, args); err != nil {
+			return err
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return parseFlags(flags, positional)
+}
+
+func parseFlags(flags *flag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, flag.ErrHelp) {
+		return USAGE_ERROR
+	}
+	return err
+}
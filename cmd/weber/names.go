@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// 由命令行传入的名称（如 order-item、order_item）得到的各种形式
+type names struct {
+	// 原始名称，小写
+	Name string
+
+	// 导出的Go标识符，如 OrderItem
+	Ident string
+
+	// 路由路径，如 order-item
+	Path string
+
+	// 复数形式，用于资源路由，如 order-items、OrderItems
+	Plural      string
+	PluralIdent string
+
+	// 文件名，如 order_item
+	File string
+}
+
+func parseName(name string) (names, error) {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r == '-' || r == '_' || r == ' '
+	})
+	if len(words) == 0 {
+		return names{}, fmt.Errorf("invalid name %q", name)
+	}
+	for _, r := range strings.Join(words, "") {
+		if r > unicode.MaxASCII || !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return names{}, fmt.Errorf("invalid name %q, use letters, digits, - or _", name)
+		}
+	}
+	if !unicode.IsLetter(rune(words[0][0])) {
+		return names{}, fmt.Errorf("invalid name %q, must start with a letter", name)
+	}
+
+	plural := append(append([]string(nil), words[:len(words)-1]...), pluralize(words[len(words)-1]))
+	return names{
+		Name:        strings.Join(words, " "),
+		Ident:       ident(words),
+		Path:        strings.Join(words, "-"),
+		Plural:      strings.Join(plural, "-"),
+		PluralIdent: ident(plural),
+		File:        strings.Join(words, "_"),
+	}, nil
+}
+
+func ident(words []string) string {
+	var builder strings.Builder
+	for _, word := range words {
+		builder.WriteString(strings.ToUpper(word[:1]) + word[1:])
+	}
+	return builder.String()
+}
+
+// 简单的英文复数规则
+func pluralize(word string) string {
+	switch {
+	case strings.HasSuffix(word, "s"), strings.HasSuffix(word, "x"), strings.HasSuffix(word, "ch"), strings.HasSuffix(word, "sh"):
+		return word + "es"
+	case len(word) > 1 && strings.HasSuffix(word, "y") && !strings.ContainsRune("aeiou", rune(word[len(word)-2])):
+		return word[:len(word)-1] + "ies"
+	}
+	return word + "s"
+}
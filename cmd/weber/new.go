@@ -0,0 +1,92 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+const weberModule = "github.com/asxlwsl/weber"
+
+// weber new：生成可直接运行的项目骨架，包含配置、路由、示例处理函数和测试
+func runNew(args []string) error {
+	flags := flag.NewFlagSet("new", flag.ContinueOnError)
+	module := flags.String("module", "", "module path, defaults to the directory name")
+	replace := flags.String("replace", "", "use a local weber checkout instead of the published module")
+	skipTidy := flags.Bool("skip-tidy", false, "do not resolve dependencies")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: weber new [-module path] [-replace dir] [-skip-tidy] <dir>")
+		flags.PrintDefaults()
+	}
+	if err := parseArgs(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return USAGE_ERROR
+	}
+
+	dir := flags.Arg(0)
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) > 0 {
+		return fmt.Errorf("%s is not empty", dir)
+	}
+	if *module == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return err
+		}
+		*module = filepath.Base(abs)
+	}
+
+	data := struct {
+		Module string
+		Name   string
+	}{Module: *module, Name: filepath.Base(*module)}
+	if err := renderDir("templates/new", dir, data); err != nil {
+		return err
+	}
+
+	if *replace != "" {
+		abs, err := filepath.Abs(*replace)
+		if err != nil {
+			return err
+		}
+		gomod, err := os.OpenFile(filepath.Join(dir, "go.mod"), os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(gomod, "\nrequire %s v0.0.0\n\nreplace %s => %s\n", weberModule, weberModule, abs)
+		if closeErr := gomod.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if !*skipTidy {
+		// 依赖解析失败（如离线）不影响项目生成，提示用户稍后手动执行
+		if *replace == "" {
+			goCommand(dir, "get", weberModule+"@latest")
+		}
+		goCommand(dir, "mod", "tidy")
+	}
+
+	fmt.Printf("\ncreated %s, run it with:\n\n  cd %s\n  go run .\n", *module, dir)
+	return nil
+}
+
+// 在dir中执行go命令，失败时只输出警告
+func goCommand(dir string, args ...string) bool {
+	cmd := exec.Command("go", args...)
+	cmd.Dir = dir
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: go %s failed: %v\n", args[0], err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+
+	"github.com/asxlwsl/weber/server"
+)
+
+// weber routes：以dry-run模式编译运行项目，输出其中注册的所有路由
+// 项目需要在启动服务前完成路由注册，并把服务启动返回的错误返回或输出
+func runRoutes(args []string) error {
+	flags := flag.NewFlagSet("routes", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the route table as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: weber routes [-json] [dir]")
+		flags.PrintDefaults()
+	}
+	if err := parseArgs(flags, args); err != nil {
+		return err
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return USAGE_ERROR
+	}
+	dir := "."
+	if flags.NArg() > 0 {
+		dir = flags.Arg(0)
+	}
+
+	output, err := os.CreateTemp("", "weber-routes-*.json")
+	if err != nil {
+		return err
+	}
+	output.Close()
+	defer os.Remove(output.Name())
+
+	cmd := exec.Command("go", "run", ".")
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), server.ENV_DRY_RUN+"="+output.Name())
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// 项目把DRY_RUN当作错误退出时也能读到路由表，以是否写入了路由表为准
+	runErr := cmd.Run()
+
+	data, err := os.ReadFile(output.Name())
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		if runErr != nil {
+			return fmt.Errorf("go run: %w", runErr)
+		}
+		return errors.New("no route table written, make sure the project starts the server with weber")
+	}
+
+	if *asJSON {
+		_, err = os.Stdout.Write(append(data, '\n'))
+		return err
+	}
+	var table []server.RouteInfo
+	if err := json.Unmarshal(data, &table); err != nil {
+		return err
+	}
+	return server.WriteRouteTable(os.Stdout, table)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// 渲染模板并写入文件，不覆盖已有文件；.go文件会被格式化
+func render(name string, target string, data any) error {
+	if _, err := os.Stat(target); err == nil {
+		return fmt.Errorf("%s already exists", target)
+	}
+
+	text, err := templates.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	tmpl, err := template.New(filepath.Base(name)).Parse(string(text))
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	content := buf.Bytes()
+	if strings.HasSuffix(target, ".go") {
+		if content, err = format.Source(content); err != nil {
+			return fmt.Errorf("format %s: %w", target, err)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(target, content, 0o644); err != nil {
+		return err
+	}
+	fmt.Println("  create", target)
+	return nil
+}
+
+// 渲染目录下的所有模板，去掉.tmpl后缀，gitignore.tmpl写为.gitignore
+func renderDir(dir string, target string, data any) error {
+	return fs.WalkDir(templates, dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		rel := strings.TrimSuffix(strings.TrimPrefix(path, dir+"/"), ".tmpl")
+		if rel == "gitignore" {
+			rel = ".gitignore"
+		}
+		return render(path, filepath.Join(target, filepath.FromSlash(rel)), data)
+	})
+}
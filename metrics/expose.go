@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Prometheus文本格式的Content-Type
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// 以Prometheus文本格式输出所有指标，序列按标签值排序
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	metrics := append([]*metric(nil), r.metrics...)
+	r.mu.RUnlock()
+
+	counter := &countingWriter{writer: w}
+	writer := bufio.NewWriter(counter)
+	for _, m := range metrics {
+		m.writeTo(writer)
+	}
+	err := writer.Flush()
+	return counter.n, err
+}
+
+func (m *metric) writeTo(w *bufio.Writer) {
+	m.mu.RLock()
+	all := make([]*series, 0, len(m.series))
+	for _, s := range m.series {
+		all = append(all, s)
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(all, func(i, j int) bool {
+		return strings.Join(all[i].values, "\xff") < strings.Join(all[j].values, "\xff")
+	})
+
+	if m.help != "" {
+		w.WriteString("# HELP " + m.name + " " + escapeHelp(m.help) + "\n")
+	}
+	w.WriteString("# TYPE " + m.name + " " + m.kind + "\n")
+
+	for _, s := range all {
+		if m.kind != typeHistogram {
+			w.WriteString(m.name + formatLabels(m.labels, s.values, "", "") + " " + formatValue(s.get()) + "\n")
+			continue
+		}
+
+		// 分桶输出为累加值
+		var cumulative uint64
+		for idx, bound := range m.buckets {
+			cumulative += s.counts[idx].Load()
+			w.WriteString(m.name + "_bucket" + formatLabels(m.labels, s.values, "le", formatValue(bound)) + " " + strconv.FormatUint(cumulative, 10) + "\n")
+		}
+		count := s.count.Load()
+		w.WriteString(m.name + "_bucket" + formatLabels(m.labels, s.values, "le", "+Inf") + " " + strconv.FormatUint(count, 10) + "\n")
+		w.WriteString(m.name + "_sum" + formatLabels(m.labels, s.values, "", "") + " " + formatValue(s.get()) + "\n")
+		w.WriteString(m.name + "_count" + formatLabels(m.labels, s.values, "", "") + " " + strconv.FormatUint(count, 10) + "\n")
+	}
+}
+
+// extraName不为空时追加一个标签（直方图的le）
+func formatLabels(names []string, values []string, extraName string, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var builder strings.Builder
+	builder.WriteByte('{')
+	for idx, name := range names {
+		if idx > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(name + `="` + escapeLabel(values[idx]) + `"`)
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			builder.WriteByte(',')
+		}
+		builder.WriteString(extraName + `="` + extraValue + `"`)
+	}
+	builder.WriteByte('}')
+	return builder.String()
+}
+
+func formatValue(value float64) string {
+	switch {
+	case math.IsInf(value, 1):
+		return "+Inf"
+	case math.IsInf(value, -1):
+		return "-Inf"
+	case math.IsNaN(value):
+		return "NaN"
+	}
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeLabel(value string) string {
+	return labelEscaper.Replace(value)
+}
+
+func escapeHelp(help string) string {
+	return helpEscaper.Replace(help)
+}
+
+type countingWriter struct {
+	writer io.Writer
+	n      int64
+}
+
+func (c *countingWriter) Write(data []byte) (int, error) {
+	n, err := c.writer.Write(data)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,87 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/asxlwsl/weber/middleware"
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 未匹配到路由时route标签的值，避免原始URL造成序列数量膨胀
+const UnmatchedRoute = "unmatched"
+
+// 非标准请求方法时method标签的值，避免客户端任意的方法造成序列数量膨胀
+const OtherMethod = "OTHER"
+
+// HTTP请求指标
+type Metrics struct {
+	*Registry
+
+	requests *CounterVec
+	duration *HistogramVec
+	size     *HistogramVec
+	inFlight Gauge
+}
+
+// 创建HTTP请求指标，可以在Registry上继续注册自定义指标
+func New() *Metrics {
+	registry := NewRegistry()
+	return &Metrics{
+		Registry: registry,
+		requests: registry.Counter("weber_http_requests_total",
+			"Total number of HTTP requests.", "method", "route", "status"),
+		duration: registry.Histogram("weber_http_request_duration_seconds",
+			"HTTP request latency in seconds.", DefaultDurationBuckets, "method", "route", "status"),
+		size: registry.Histogram("weber_http_response_size_bytes",
+			"HTTP response size in bytes.", DefaultSizeBuckets, "method", "route", "status"),
+		inFlight: registry.Gauge("weber_http_requests_in_flight",
+			"Number of HTTP requests currently being served.").With(),
+	}
+}
+
+// 统计请求的中间件，route标签为匹配到的路由模式
+// 通过UseBeforeFlush放在Flush之前时，统计的是实际写出的状态码和大小
+func (m *Metrics) Middleware() middleware.MiddlewareHandleFunc {
+	return func(next wcontext.HandleFunc) wcontext.HandleFunc {
+		return func(ctx *wcontext.Context) {
+			start := time.Now()
+			m.inFlight.Inc()
+			defer m.inFlight.Dec()
+
+			next(ctx)
+
+			status := ctx.ResponseStatus()
+			size := int64(len(ctx.GetResponseBody()))
+			if ctx.Writer().Written() {
+				size = ctx.Writer().Size()
+			}
+			route := ctx.RoutePattern()
+			if route == "" {
+				route = UnmatchedRoute
+			}
+
+			labels := []string{normalizeMethod(ctx.GetMethod()), route, strconv.Itoa(status)}
+			m.requests.With(labels...).Inc()
+			m.duration.With(labels...).Observe(time.Since(start).Seconds())
+			m.size.With(labels...).Observe(float64(size))
+		}
+	}
+}
+
+func normalizeMethod(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodOptions, http.MethodConnect, http.MethodTrace:
+		return method
+	}
+	return OtherMethod
+}
+
+// 以Prometheus文本格式输出指标
+func (m *Metrics) Handle(ctx *wcontext.Context) {
+	ctx.SetStatusCode(http.StatusOK)
+	ctx.SetResponseHeader(wcontext.CONTENT_TYPE, ContentType)
+	m.WriteTo(ctx.Writer())
+}
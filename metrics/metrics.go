@@ -0,0 +1,245 @@
+// metrics 提供无外部依赖、兼容Prometheus文本格式的指标
+//
+//	m := metrics.New()
+//	srv.Use(m.Middleware())
+//	srv.GET("/metrics", m.Handle)
+//
+// 或直接使用 srv.EnableMetrics("/metrics")
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var (
+	// 请求耗时的默认分桶（秒）
+	DefaultDurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+	// 响应大小的默认分桶（字节）
+	DefaultSizeBuckets = []float64{100, 1000, 10000, 100000, 1000000, 10000000}
+)
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+// 指标注册表，输出时按注册顺序排列
+type Registry struct {
+	mu      sync.RWMutex
+	metrics []*metric
+	names   map[string]*metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]*metric)}
+}
+
+// 一个指标及其按标签区分的所有序列
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	labels  []string
+	buckets []float64
+
+	mu     sync.RWMutex
+	series map[string]*series
+}
+
+// 一组标签值对应的序列
+type series struct {
+	values []string
+
+	// 计数器、仪表盘的值，直方图的总和，保存float64的位
+	value atomic.Uint64
+
+	// 直方图各分桶（不累加）及总数
+	counts []atomic.Uint64
+	count  atomic.Uint64
+}
+
+func (s *series) add(delta float64) {
+	for {
+		old := s.value.Load()
+		if s.value.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+delta)) {
+			return
+		}
+	}
+}
+
+func (s *series) set(value float64) {
+	s.value.Store(math.Float64bits(value))
+}
+
+func (s *series) get() float64 {
+	return math.Float64frombits(s.value.Load())
+}
+
+// 注册指标，同名指标重复注册时返回已有的指标，类型或标签不同时panic
+func (r *Registry) register(name string, help string, kind string, buckets []float64, labels []string) *metric {
+	if !metricName.MatchString(name) {
+		panic(fmt.Sprintf("metrics: invalid metric name %q", name))
+	}
+	for _, label := range labels {
+		if !metricName.MatchString(label) || strings.Contains(label, ":") {
+			panic(fmt.Sprintf("metrics: invalid label name %q", label))
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.names[name]; ok {
+		if existing.kind != kind || strings.Join(existing.labels, ",") != strings.Join(labels, ",") {
+			panic(fmt.Sprintf("metrics: %q already registered with different type or labels", name))
+		}
+		return existing
+	}
+
+	if kind == typeHistogram {
+		buckets = append([]float64(nil), buckets...)
+		sort.Float64s(buckets)
+	}
+	m := &metric{
+		name:    name,
+		help:    help,
+		kind:    kind,
+		labels:  append([]string(nil), labels...),
+		buckets: buckets,
+		series:  make(map[string]*series),
+	}
+	r.metrics = append(r.metrics, m)
+	r.names[name] = m
+	return m
+}
+
+// 获取标签值对应的序列，不存在时创建
+func (m *metric) with(values []string) *series {
+	if len(values) != len(m.labels) {
+		panic(fmt.Sprintf("metrics: %q expects %d label values, got %d", m.name, len(m.labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	m.mu.RLock()
+	s, ok := m.series[key]
+	m.mu.RUnlock()
+	if ok {
+		return s
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok = m.series[key]; ok {
+		return s
+	}
+	s = &series{values: append([]string(nil), values...)}
+	if m.kind == typeHistogram {
+		s.counts = make([]atomic.Uint64, len(m.buckets))
+	}
+	m.series[key] = s
+	return s
+}
+
+// 计数器，只增不减
+type CounterVec struct {
+	metric *metric
+}
+
+type Counter struct {
+	series *series
+}
+
+func (r *Registry) Counter(name string, help string, labels ...string) *CounterVec {
+	return &CounterVec{metric: r.register(name, help, typeCounter, nil, labels)}
+}
+
+// 按标签值（与注册时的标签顺序一致）获取计数器
+func (v *CounterVec) With(values ...string) Counter {
+	return Counter{series: v.metric.with(values)}
+}
+
+func (c Counter) Inc() {
+	c.series.add(1)
+}
+
+// delta不能为负数
+func (c Counter) Add(delta float64) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.series.add(delta)
+}
+
+// 仪表盘，可增可减
+type GaugeVec struct {
+	metric *metric
+}
+
+type Gauge struct {
+	series *series
+}
+
+func (r *Registry) Gauge(name string, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{metric: r.register(name, help, typeGauge, nil, labels)}
+}
+
+func (v *GaugeVec) With(values ...string) Gauge {
+	return Gauge{series: v.metric.with(values)}
+}
+
+func (g Gauge) Set(value float64) {
+	g.series.set(value)
+}
+
+func (g Gauge) Add(delta float64) {
+	g.series.add(delta)
+}
+
+func (g Gauge) Inc() {
+	g.series.add(1)
+}
+
+func (g Gauge) Dec() {
+	g.series.add(-1)
+}
+
+// 直方图
+type HistogramVec struct {
+	metric *metric
+}
+
+type Histogram struct {
+	metric *metric
+	series *series
+}
+
+// buckets为各分桶的上界，为空时使用DefaultDurationBuckets
+func (r *Registry) Histogram(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultDurationBuckets
+	}
+	return &HistogramVec{metric: r.register(name, help, typeHistogram, buckets, labels)}
+}
+
+func (v *HistogramVec) With(values ...string) Histogram {
+	return Histogram{metric: v.metric, series: v.metric.with(values)}
+}
+
+func (h Histogram) Observe(value float64) {
+	idx := sort.SearchFloat64s(h.metric.buckets, value)
+	if idx < len(h.series.counts) {
+		h.series.counts[idx].Add(1)
+	}
+	h.series.count.Add(1)
+	h.series.add(value)
+}
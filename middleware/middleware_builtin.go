@@ -2,7 +2,7 @@ package middleware
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"runtime"
 	"strings"
@@ -27,8 +27,8 @@ func Recovery() MiddlewareHandleFunc {
 		return func(ctx *wcontext.Context) {
 			defer func() {
 				if err := recover(); err != nil {
-					message := fmt.Sprintf("%s", err)
-					log.Println(Trace(message))
+					// 跳过defer函数和runtime.gopanic，从发生panic的位置开始
+					ctx.Logger().Error("panic recovered", "error", fmt.Sprintf("%v", err), "stack", stack(2))
 					ctx.Fail(http.StatusInternalServerError, "500 InternalServerError")
 				}
 			}()
@@ -38,27 +38,46 @@ func Recovery() MiddlewareHandleFunc {
 }
 
 func Trace(message string) string {
-	var pcs [32]uintptr
-	n := runtime.Callers(3, pcs[:])
-
 	var str strings.Builder
 	str.WriteString(message + "\nTraceback:")
+	for _, frame := range stack(2) {
+		str.WriteString("\n\t" + frame)
+	}
+	return str.String()
+}
+
+// 调用栈，skip为跳过调用方之上的层数
+func stack(skip int) []string {
+	var pcs [32]uintptr
+	n := runtime.Callers(skip+2, pcs[:])
+
+	frames := make([]string, 0, n)
 	for _, pc := range pcs[:n] {
 		fn := runtime.FuncForPC(pc)
 		file, line := fn.FileLine(pc)
-		str.WriteString(fmt.Sprintf("\n\t%s:%d", file, line))
+		frames = append(frames, fmt.Sprintf("%s:%d", file, line))
 	}
-	return str.String()
+	return frames
 }
 
+// 访问日志，每个请求处理完成后输出一条，5xx为Error级别，其余为Info级别
 func Logger() MiddlewareHandleFunc {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx *wcontext.Context) {
-			ctime := time.Now()
-			fmt.Printf("请求进入时间：%v\n", ctime)
-			time.Sleep(time.Second * 1)
+			start := time.Now()
 			next(ctx)
-			fmt.Printf("请求返回时间：%v\n", time.Now())
+
+			status := ctx.ResponseStatus()
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+			ctx.Logger().LogAttrs(ctx.Request().Context(), level, "request",
+				slog.String("method", ctx.GetMethod()),
+				slog.String("path", ctx.Request().URL.Path),
+				slog.Int("status", status),
+				slog.Duration("duration", time.Since(start)),
+			)
 		}
 	}
 }
@@ -66,9 +85,9 @@ func Logger() MiddlewareHandleFunc {
 func RequestFilter() MiddlewareHandleFunc {
 	return func(next HandleFunc) HandleFunc {
 		return func(ctx *wcontext.Context) {
-			fmt.Println("进入Filter")
+			ctx.Logger().Debug("enter filter")
 			next(ctx)
-			fmt.Println("退出Filter")
+			ctx.Logger().Debug("exit filter")
 		}
 	}
 }
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// 根据配置项创建中间件，用于从配置文件启用内置中间件
+type Factory func(options map[string]string) (MiddlewareHandleFunc, error)
+
+var (
+	factoryMu sync.RWMutex
+	factories = map[string]Factory{}
+)
+
+var (
+	UNKNOWN_MIDDLEWARE = errors.New("unknown middleware")
+)
+
+// 中间件选项错误，Key为出错的选项名
+type OptionError struct {
+	Key string
+	Err error
+}
+
+func (e *OptionError) Error() string {
+	return fmt.Sprintf("option %s: %v", e.Key, e.Err)
+}
+
+func (e *OptionError) Unwrap() error {
+	return e.Err
+}
+
+// 按名称注册中间件工厂，重复注册会覆盖
+func Register(name string, factory Factory) {
+	factoryMu.Lock()
+	defer factoryMu.Unlock()
+	factories[name] = factory
+}
+
+// 按名称创建中间件，名称未注册时返回UNKNOWN_MIDDLEWARE，选项错误时返回*OptionError
+func Build(name string, options map[string]string) (MiddlewareHandleFunc, error) {
+	factoryMu.RLock()
+	factory, ok := factories[name]
+	factoryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", UNKNOWN_MIDDLEWARE, name)
+	}
+	return factory(options)
+}
+
+// 已注册的中间件名称
+func Registered() []string {
+	factoryMu.RLock()
+	defer factoryMu.RUnlock()
+	names := make([]string, 0, len(factories))
+	for name := range factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func init() {
+	Register("logger", func(options map[string]string) (MiddlewareHandleFunc, error) {
+		return Logger(), nil
+	})
+	Register("request_filter", func(options map[string]string) (MiddlewareHandleFunc, error) {
+		return RequestFilter(), nil
+	})
+	// options: header（默认X-Request-ID）、generator（uuid或ulid）、trust_incoming（默认true）
+	Register("request_id", func(options map[string]string) (MiddlewareHandleFunc, error) {
+		var opts []RequestIDOption
+		if header := options["header"]; header != "" {
+			opts = append(opts, WithRequestIDHeader(header))
+		}
+		switch options["generator"] {
+		case "", "uuid":
+		case "ulid":
+			opts = append(opts, WithIDGenerator(ULID))
+		default:
+			return nil, &OptionError{Key: "generator", Err: fmt.Errorf("unknown request id generator %q", options["generator"])}
+		}
+		if trust := options["trust_incoming"]; trust != "" {
+			value, err := strconv.ParseBool(trust)
+			if err != nil {
+				return nil, &OptionError{Key: "trust_incoming", Err: fmt.Errorf("invalid value %q", trust)}
+			}
+			opts = append(opts, WithTrustIncoming(value))
+		}
+		return RequestID(opts...), nil
+	})
+}
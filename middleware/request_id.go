@@ -0,0 +1,185 @@
+package middleware
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 请求ID的默认请求头
+const DefaultRequestIDHeader = wcontext.REQUEST_ID_HEADER
+
+// 上游传入的请求ID的最大长度，超过时重新生成
+const maxRequestIDLength = 128
+
+// 生成请求ID
+type IDGenerator func() string
+
+type requestIDConfig struct {
+	header    string
+	generator IDGenerator
+
+	// 是否沿用上游传入的请求ID
+	trustIncoming bool
+}
+
+type RequestIDOption func(config *requestIDConfig)
+
+// 读取和回写请求ID的请求头，默认X-Request-ID
+func WithRequestIDHeader(header string) RequestIDOption {
+	return func(config *requestIDConfig) {
+		config.header = http.CanonicalHeaderKey(header)
+	}
+}
+
+// 请求ID的生成方式，默认UUIDv4
+func WithIDGenerator(generator IDGenerator) RequestIDOption {
+	return func(config *requestIDConfig) {
+		config.generator = generator
+	}
+}
+
+// 是否沿用上游传入的请求ID，默认沿用；直接面向公网时可以关闭，避免客户端伪造
+func WithTrustIncoming(trust bool) RequestIDOption {
+	return func(config *requestIDConfig) {
+		config.trustIncoming = trust
+	}
+}
+
+func newRequestIDConfig(options []RequestIDOption) *requestIDConfig {
+	config := &requestIDConfig{header: DefaultRequestIDHeader, generator: UUIDv4, trustIncoming: true}
+	for _, option := range options {
+		option(config)
+	}
+	return config
+}
+
+type requestIDKey struct{}
+
+// 请求ID：沿用请求头中的ID或生成新的ID，保存到上下文（ctx.RequestID()、ctx.Logger()），并写入响应头
+// 同时写回请求头和请求的上下文，反向代理和RequestIDClient发出的请求会继续携带
+// 放在最前以便所有日志都带有请求ID
+//
+//	srv.UseBeforeFlush(middleware.RequestID(middleware.WithIDGenerator(middleware.ULID)))
+func RequestID(options ...RequestIDOption) MiddlewareHandleFunc {
+	config := newRequestIDConfig(options)
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *wcontext.Context) {
+			request := ctx.Request()
+			id := ""
+			if config.trustIncoming {
+				id = request.Header.Get(config.header)
+			}
+			if !validRequestID(id) {
+				id = config.generator()
+			}
+
+			request.Header.Set(config.header, id)
+			ctx.SetRequest(request.WithContext(context.WithValue(request.Context(), requestIDKey{}, id)))
+			ctx.SetRequestID(id)
+			ctx.SetResponseHeader(config.header, id)
+			next(ctx)
+		}
+	}
+}
+
+// 只接受可见的ASCII字符，避免日志注入
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// 从请求的上下文中获取请求ID，没有时返回空字符串
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// 为发出的请求添加请求ID的RoundTripper，请求ID取自请求的上下文
+// base为nil时使用http.DefaultTransport，options中只有请求头的设置生效
+func RequestIDTransport(base http.RoundTripper, options ...RequestIDOption) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &requestIDTransport{base: base, header: newRequestIDConfig(options).header}
+}
+
+// 转发请求ID的http.Client，发出的请求需要使用处理中请求的上下文
+//
+//	client := middleware.RequestIDClient()
+//	req, _ := http.NewRequestWithContext(ctx.Request().Context(), http.MethodGet, url, nil)
+//	resp, err := client.Do(req)
+func RequestIDClient(options ...RequestIDOption) *http.Client {
+	return &http.Client{Transport: RequestIDTransport(nil, options...)}
+}
+
+type requestIDTransport struct {
+	base   http.RoundTripper
+	header string
+}
+
+func (t *requestIDTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	id := RequestIDFromContext(request.Context())
+	if id == "" || request.Header.Get(t.header) != "" {
+		return t.base.RoundTrip(request)
+	}
+	// RoundTripper不能修改传入的请求
+	request = request.Clone(request.Context())
+	request.Header.Set(t.header, id)
+	return t.base.RoundTrip(request)
+}
+
+// 随机生成的UUID（版本4），如 0b5c6f5e-4d0a-4c1e-9a43-2f0c8e0d9b71
+func UUIDv4() string {
+	var uuid [16]byte
+	rand.Read(uuid[:])
+	uuid[6] = uuid[6]&0x0f | 0x40
+	uuid[8] = uuid[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], uuid[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], uuid[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], uuid[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], uuid[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], uuid[10:])
+	return string(buf[:])
+}
+
+// ULID使用的Crockford Base32字符表
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID：48位毫秒时间戳加80位随机数，26个字符，按生成时间排序
+func ULID() string {
+	var ulid [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	binary.BigEndian.PutUint16(ulid[0:2], uint16(ms>>32))
+	binary.BigEndian.PutUint32(ulid[2:6], uint32(ms))
+	rand.Read(ulid[6:])
+
+	// 128位按5位一组编码，最高位的一组只有3位
+	high := binary.BigEndian.Uint64(ulid[0:8])
+	low := binary.BigEndian.Uint64(ulid[8:16])
+	var buf [26]byte
+	for i := 25; i >= 0; i-- {
+		buf[i] = crockford[low&0x1f]
+		low = low>>5 | high<<59
+		high >>= 5
+	}
+	return string(buf[:])
+}
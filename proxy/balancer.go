@@ -0,0 +1,79 @@
+package proxy
+
+import (
+	"hash/fnv"
+	"sync/atomic"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 负载均衡策略，candidates为当前可用的上游，不会为空
+type Balancer interface {
+	Next(ctx *wcontext.Context, candidates []*Upstream) *Upstream
+}
+
+// 轮询
+func RoundRobin() Balancer {
+	return &roundRobin{}
+}
+
+type roundRobin struct {
+	next atomic.Uint64
+}
+
+func (b *roundRobin) Next(ctx *wcontext.Context, candidates []*Upstream) *Upstream {
+	idx := b.next.Add(1) - 1
+	return candidates[idx%uint64(len(candidates))]
+}
+
+// 最少连接，选择正在处理的请求数最少的上游
+func LeastConn() Balancer {
+	return leastConn{}
+}
+
+type leastConn struct{}
+
+func (leastConn) Next(ctx *wcontext.Context, candidates []*Upstream) *Upstream {
+	best := candidates[0]
+	for _, up := range candidates[1:] {
+		if up.Inflight() < best.Inflight() {
+			best = up
+		}
+	}
+	return best
+}
+
+// 一致性哈希，相同key的请求转发到同一个上游，上游增减时只影响少量key
+// 使用最高随机权重（rendezvous）哈希，key为空时使用客户端IP
+func ConsistentHash(key func(ctx *wcontext.Context) string) Balancer {
+	if key == nil {
+		key = ClientIP
+	}
+	return consistentHash{key: key}
+}
+
+type consistentHash struct {
+	key func(ctx *wcontext.Context) string
+}
+
+func (b consistentHash) Next(ctx *wcontext.Context, candidates []*Upstream) *Upstream {
+	key := b.key(ctx)
+
+	var best *Upstream
+	var bestScore uint64
+	for _, up := range candidates {
+		hash := fnv.New64a()
+		hash.Write([]byte(up.URL.String()))
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+		if score := hash.Sum64(); best == nil || score > bestScore {
+			best, bestScore = up, score
+		}
+	}
+	return best
+}
+
+// 客户端IP（不含端口）
+func ClientIP(ctx *wcontext.Context) string {
+	return ctx.ClientIP()
+}
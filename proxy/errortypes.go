@@ -0,0 +1,8 @@
+package proxy
+
+import "errors"
+
+var (
+	INVALID_UPSTREAM = errors.New("upstream must be an absolute URL!")
+	NO_UPSTREAM      = errors.New("at least one upstream is required!")
+)
@@ -0,0 +1,106 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+type healthConfig struct {
+	path     string
+	interval time.Duration
+	timeout  time.Duration
+}
+
+// 主动健康检查：定期请求每个上游的path，2xx/3xx视为健康
+// Start之后才开始检查，通过RouterGroup.Proxy注册时随服务启动和关闭
+func (p *Proxy) HealthCheck(path string, interval time.Duration, timeout time.Duration) *Proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.health = &healthConfig{path: path, interval: interval, timeout: timeout}
+	if p.running {
+		p.startHealth()
+	}
+	return p
+}
+
+// 开始主动健康检查，重复调用无效
+func (p *Proxy) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.running {
+		return nil
+	}
+	p.running = true
+	p.startHealth()
+	return nil
+}
+
+// 调用时需持有p.mu
+func (p *Proxy) startHealth() {
+	p.stopHealthLocked()
+	if p.health == nil {
+		return
+	}
+	stop := make(chan struct{})
+	p.stopHealth = stop
+	health := *p.health
+
+	go func() {
+		ticker := time.NewTicker(health.interval)
+		defer ticker.Stop()
+
+		p.checkAll(health.path, health.timeout)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				p.checkAll(health.path, health.timeout)
+			}
+		}
+	}()
+}
+
+func (p *Proxy) stopHealthLocked() {
+	if p.stopHealth != nil {
+		close(p.stopHealth)
+		p.stopHealth = nil
+	}
+}
+
+func (p *Proxy) checkAll(path string, timeout time.Duration) {
+	for _, up := range p.upstreams {
+		up.healthy.Store(p.check(up, path, timeout))
+	}
+}
+
+func (p *Proxy) check(up *Upstream, path string, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	target := *up.URL
+	target.Path = singleJoiningSlash(target.Path, path)
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return false
+	}
+	resp, err := p.roundTripper().RoundTrip(request)
+	if err != nil {
+		return false
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	return resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// 停止健康检查
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+	p.stopHealthLocked()
+	return nil
+}
@@ -0,0 +1,431 @@
+// 反向代理：将请求转发到一组上游服务
+// 支持轮询、最少连接和一致性哈希负载均衡，主动健康检查，连续失败的被动摘除，
+// 幂等请求的失败重试，以及X-Forwarded-*请求头
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 代理路由中通配参数的名称，例如 /api/*proxypath
+const PROXY_PARAM = "proxypath"
+
+const (
+	// 默认连续失败3次后摘除10秒
+	DefaultMaxFails    = 3
+	DefaultFailTimeout = 10 * time.Second
+
+	// 为了重试而缓冲的请求体上限，超过时不再重试
+	DefaultMaxRetryBody = 1 << 20
+)
+
+// 逐跳请求头，不能转发
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+type Proxy struct {
+	mu sync.Mutex
+
+	// 代理的路由前缀
+	prefix string
+
+	// 转发时是否去掉前缀
+	stripPrefix bool
+
+	upstreams []*Upstream
+
+	balancer Balancer
+
+	transport http.RoundTripper
+
+	// 幂等请求失败后最多重试的次数（换一个上游）
+	retries int
+
+	// 为了重试而缓冲的请求体上限
+	maxRetryBody int64
+
+	// 被动摘除
+	maxFails    int
+	failTimeout time.Duration
+
+	// 转发前修改请求（ctx.Request()），收到响应后修改响应（状态码、响应头和响应体）
+	modifyRequest  []wcontext.HandleFunc
+	modifyResponse []wcontext.HandleFunc
+
+	// 主动健康检查的设置，为nil时不检查
+	health *healthConfig
+
+	// 是否已启动，启动后才开始主动健康检查
+	running bool
+
+	// 停止主动健康检查
+	stopHealth chan struct{}
+}
+
+// 创建代理，upstreams为上游地址，例如 http://10.0.0.1:8080
+func New(prefix string, upstreams ...string) (*Proxy, error) {
+	if len(upstreams) == 0 {
+		return nil, NO_UPSTREAM
+	}
+	p := &Proxy{
+		prefix:       prefix,
+		balancer:     RoundRobin(),
+		transport:    http.DefaultTransport,
+		maxFails:     DefaultMaxFails,
+		failTimeout:  DefaultFailTimeout,
+		maxRetryBody: DefaultMaxRetryBody,
+	}
+	for _, upstream := range upstreams {
+		target, err := url.Parse(upstream)
+		if err != nil {
+			return nil, err
+		}
+		if target.Scheme == "" || target.Host == "" {
+			return nil, fmt.Errorf("%q: %w", upstream, INVALID_UPSTREAM)
+		}
+		p.upstreams = append(p.upstreams, newUpstream(target))
+	}
+	return p, nil
+}
+
+// 设置负载均衡策略，默认轮询
+func (p *Proxy) Balance(balancer Balancer) *Proxy {
+	p.balancer = balancer
+	return p
+}
+
+// 设置转发使用的Transport，默认http.DefaultTransport
+func (p *Proxy) Transport(transport http.RoundTripper) *Proxy {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.transport = transport
+	return p
+}
+
+// 健康检查协程与处理请求时并发读取
+func (p *Proxy) roundTripper() http.RoundTripper {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.transport
+}
+
+// 设置幂等请求（GET、HEAD、OPTIONS、PUT、DELETE）失败后的重试次数
+func (p *Proxy) Retries(retries int) *Proxy {
+	p.retries = retries
+	return p
+}
+
+// 设置为了重试而缓冲的请求体上限，请求体超过上限时直接转发，失败后不再重试
+func (p *Proxy) MaxRetryBody(size int64) *Proxy {
+	p.maxRetryBody = size
+	return p
+}
+
+// 设置被动摘除：连续失败maxFails次后摘除failTimeout，maxFails为0时不摘除
+func (p *Proxy) PassiveEject(maxFails int, failTimeout time.Duration) *Proxy {
+	p.maxFails = maxFails
+	p.failTimeout = failTimeout
+	return p
+}
+
+// 转发时去掉路由前缀，/api/users -> 上游的/users
+func (p *Proxy) StripPrefix() *Proxy {
+	p.stripPrefix = true
+	return p
+}
+
+// 转发前执行，可以通过ctx.Request()修改请求头等
+func (p *Proxy) ModifyRequest(handlers ...wcontext.HandleFunc) *Proxy {
+	p.modifyRequest = append(p.modifyRequest, handlers...)
+	return p
+}
+
+// 收到上游响应后执行，可以修改状态码、响应头和响应体
+// 设置后上游响应会被完整读取到内存中，不适合事件流和大文件
+func (p *Proxy) ModifyResponse(handlers ...wcontext.HandleFunc) *Proxy {
+	p.modifyResponse = append(p.modifyResponse, handlers...)
+	return p
+}
+
+// 所有上游
+func (p *Proxy) Upstreams() []*Upstream {
+	return append([]*Upstream(nil), p.upstreams...)
+}
+
+// 视图函数
+func (p *Proxy) Handle(ctx *wcontext.Context) {
+	for _, handler := range p.modifyRequest {
+		handler(ctx)
+	}
+
+	request := ctx.Request()
+
+	attempts := 1
+	if idempotent(request.Method) {
+		attempts += p.retries
+	}
+
+	// 可能重试时才缓冲请求体，以便重新发送；否则直接转发原请求体
+	var body []byte
+	var stream io.Reader
+	if request.Body != nil && request.Body != http.NoBody {
+		stream = request.Body
+		if attempts > 1 {
+			data, err := io.ReadAll(io.LimitReader(request.Body, p.maxRetryBody+1))
+			if err != nil {
+				ctx.Fail(http.StatusBadRequest, "400 Bad Request")
+				return
+			}
+			if int64(len(data)) <= p.maxRetryBody {
+				body, stream = data, nil
+			} else {
+				// 超过上限不再重试，已读取的部分和剩余部分一起转发
+				attempts = 1
+				stream = io.MultiReader(bytes.NewReader(data), request.Body)
+			}
+		}
+	}
+
+	tried := make(map[*Upstream]bool)
+	for attempt := 0; attempt < attempts; attempt++ {
+		up := p.pick(ctx, tried)
+		if up == nil {
+			break
+		}
+		tried[up] = true
+
+		resp, err := p.forward(ctx, up, body, stream)
+		if err != nil && ctx.Request().Context().Err() != nil {
+			// 客户端已断开，不是上游的故障，不标记失败也不重试
+			ctx.Logger().Debug("proxy client gone", "upstream", up.URL.String(), "error", err)
+			return
+		}
+		if err != nil {
+			ctx.Logger().Warn("proxy upstream failed", "upstream", up.URL.String(), "error", err)
+			up.markFailed(p.maxFails, p.failTimeout)
+			continue
+		}
+
+		// 网关类错误视为上游故障，可以重试
+		if isUpstreamFailure(resp.StatusCode) && attempt+1 < attempts {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			up.markFailed(p.maxFails, p.failTimeout)
+			continue
+		}
+		if isUpstreamFailure(resp.StatusCode) {
+			up.markFailed(p.maxFails, p.failTimeout)
+		} else {
+			up.markSucceeded()
+		}
+
+		if len(p.modifyResponse) == 0 {
+			p.streamResponse(ctx, up, resp)
+			return
+		}
+		if err := p.copyResponse(ctx, resp); err != nil {
+			ctx.Logger().Warn("proxy upstream failed", "upstream", up.URL.String(), "error", err)
+			ctx.Fail(http.StatusBadGateway, "502 Bad Gateway")
+			return
+		}
+		for _, handler := range p.modifyResponse {
+			handler(ctx)
+		}
+		return
+	}
+
+	ctx.Fail(http.StatusBadGateway, "502 Bad Gateway")
+}
+
+// 选择一个尚未尝试过的可用上游
+func (p *Proxy) pick(ctx *wcontext.Context, tried map[*Upstream]bool) *Upstream {
+	candidates := make([]*Upstream, 0, len(p.upstreams))
+	for _, up := range p.upstreams {
+		if !tried[up] && up.Available() {
+			candidates = append(candidates, up)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	return p.balancer.Next(ctx, candidates)
+}
+
+// body为缓冲的请求体，stream为未缓冲的请求体（只能发送一次）
+func (p *Proxy) forward(ctx *wcontext.Context, up *Upstream, body []byte, stream io.Reader) (*http.Response, error) {
+	request := ctx.Request()
+
+	path := request.URL.Path
+	if p.stripPrefix {
+		path = "/" + strings.TrimPrefix(strings.TrimPrefix(path, p.prefix), "/")
+	}
+	target := *up.URL
+	target.Path = singleJoiningSlash(up.URL.Path, path)
+	target.RawQuery = request.URL.RawQuery
+
+	var reader io.Reader = http.NoBody
+	switch {
+	case body != nil:
+		reader = bytes.NewReader(body)
+	case stream != nil:
+		reader = stream
+	}
+	out, err := http.NewRequestWithContext(request.Context(), request.Method, target.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if stream != nil {
+		out.ContentLength = request.ContentLength
+	}
+	out.Header = request.Header.Clone()
+	removeHopHeaders(out.Header)
+	setForwardedHeaders(out.Header, request)
+
+	up.inflight.Add(1)
+	defer up.inflight.Add(-1)
+
+	return p.roundTripper().RoundTrip(out)
+}
+
+// 边读边写出上游响应，适用于事件流、长轮询和大文件
+// 长度未知（分块传输、事件流）时每次写入后立即刷新
+func (p *Proxy) streamResponse(ctx *wcontext.Context, up *Upstream, resp *http.Response) {
+	defer resp.Body.Close()
+
+	header := resp.Header.Clone()
+	removeHopHeaders(header)
+	target := ctx.ResponseHeader()
+	for key, values := range header {
+		target[key] = values
+	}
+
+	writer := ctx.Writer()
+	flush := resp.ContentLength < 0
+	if flush {
+		// 长连接不受服务端写超时限制
+		http.NewResponseController(writer).SetWriteDeadline(time.Time{})
+	}
+	writer.WriteHeader(resp.StatusCode)
+
+	// 响应头已写出，出错时只能中断响应
+	if err := copyBody(writer, resp.Body, flush); err != nil && ctx.Request().Context().Err() == nil {
+		ctx.Logger().Warn("proxy copy response failed", "upstream", up.URL.String(), "error", err)
+	}
+}
+
+func copyBody(writer wcontext.ResponseWriter, body io.Reader, flush bool) error {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, err := writer.Write(buf[:n]); err != nil {
+				return err
+			}
+			if flush {
+				writer.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// 将上游响应读取到上下文中，供ModifyResponse修改
+func (p *Proxy) copyResponse(ctx *wcontext.Context, resp *http.Response) error {
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	header := resp.Header.Clone()
+	removeHopHeaders(header)
+	// 响应体可能被ModifyResponse修改，由net/http重新计算长度
+	header.Del("Content-Length")
+
+	target := ctx.ResponseHeader()
+	for key, values := range header {
+		target[key] = values
+	}
+	ctx.SetStatusCode(resp.StatusCode)
+	ctx.SetResponseBody(data)
+	return nil
+}
+
+func removeHopHeaders(header http.Header) {
+	// Connection中列出的请求头也是逐跳的
+	for _, value := range header.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+func setForwardedHeaders(header http.Header, request *http.Request) {
+	if ip, _, err := net.SplitHostPort(request.RemoteAddr); err == nil {
+		if prior := header.Get("X-Forwarded-For"); prior != "" {
+			ip = prior + ", " + ip
+		}
+		header.Set("X-Forwarded-For", ip)
+	}
+	// 总是以实际收到的请求为准，客户端传入的值可能是伪造的
+	header.Set("X-Forwarded-Host", request.Host)
+	proto := "http"
+	if request.TLS != nil {
+		proto = "https"
+	}
+	header.Set("X-Forwarded-Proto", proto)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+func isUpstreamFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+func singleJoiningSlash(a string, b string) string {
+	aslash := strings.HasSuffix(a, "/")
+	bslash := strings.HasPrefix(b, "/")
+	switch {
+	case aslash && bslash:
+		return a + b[1:]
+	case !aslash && !bslash:
+		return a + "/" + b
+	}
+	return a + b
+}
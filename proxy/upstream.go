@@ -0,0 +1,55 @@
+package proxy
+
+import (
+	"net/url"
+	"sync/atomic"
+	"time"
+)
+
+// 上游服务
+type Upstream struct {
+	URL *url.URL
+
+	// 主动健康检查的结果，未开启健康检查时始终为true
+	healthy atomic.Bool
+
+	// 连续失败次数
+	fails atomic.Int32
+
+	// 被动摘除的截止时间（UnixNano）
+	ejectedUntil atomic.Int64
+
+	// 正在处理的请求数
+	inflight atomic.Int64
+}
+
+func newUpstream(target *url.URL) *Upstream {
+	up := &Upstream{URL: target}
+	up.healthy.Store(true)
+	return up
+}
+
+// 是否可以转发请求
+func (u *Upstream) Available() bool {
+	return u.healthy.Load() && time.Now().UnixNano() >= u.ejectedUntil.Load()
+}
+
+// 正在处理的请求数
+func (u *Upstream) Inflight() int64 {
+	return u.inflight.Load()
+}
+
+// 转发失败，连续失败达到maxFails后摘除一段时间
+func (u *Upstream) markFailed(maxFails int, ejectFor time.Duration) {
+	if maxFails <= 0 {
+		return
+	}
+	if int(u.fails.Add(1)) >= maxFails {
+		u.ejectedUntil.Store(time.Now().Add(ejectFor).UnixNano())
+		u.fails.Store(0)
+	}
+}
+
+func (u *Upstream) markSucceeded() {
+	u.fails.Store(0)
+}
@@ -1,7 +1,6 @@
 package router
 
 import (
-	"fmt"
 	"log"
 	"strings"
 
@@ -36,7 +35,6 @@ func getNodeType(part string) int {
 	default:
 		nType = RNODE
 	}
-	log.Println(part, " TYPE:", nType)
 	return nType
 }
 
@@ -45,9 +43,43 @@ func handleIndexFunc(ctx *wcontext.Context) {
 }
 
 type Router struct {
-	roots        map[string]*node
-	handlers     map[string]wcontext.HandleFunc
-	handleChains map[string]HandleChain
+	roots map[string]*node
+
+	// 按注册顺序保存的路由
+	routes []*Route
+
+	// 未匹配到路由、匹配到路由但方法不支持时的处理函数
+	notFound         wcontext.HandleFunc
+	methodNotAllowed wcontext.HandleFunc
+}
+
+// 注册的路由
+type Route struct {
+	Method  string
+	Pattern string
+
+	// 视图函数
+	Handler wcontext.HandleFunc
+
+	// 路由级中间件
+	Chain HandleChain
+
+	// 编译好的完整处理链，请求时直接调用
+	handle wcontext.HandleFunc
+}
+
+// 设置编译好的处理链
+func (rt *Route) SetHandle(handle wcontext.HandleFunc) {
+	rt.handle = handle
+}
+
+// 将路由级中间件包裹在视图函数外（从内向外）
+func (rt *Route) compose() wcontext.HandleFunc {
+	fn := rt.Handler
+	for i := len(rt.Chain) - 1; i >= 0; i-- {
+		fn = rt.Chain[i](fn)
+	}
+	return fn
 }
 
 // 参数类型的泛型限定 string,map,slice
@@ -57,16 +89,16 @@ type ParamType interface {
 
 func NewRouter() *Router {
 	r := &Router{
-		roots:        make(map[string]*node),
-		handlers:     make(map[string]wcontext.HandleFunc),
-		handleChains: make(map[string]middleware.HandleChain),
+		roots:            make(map[string]*node),
+		notFound:         wcontext.HandleNotFound(),
+		methodNotAllowed: wcontext.HandleMethodNotAllowed(),
 	}
 
 	r.roots[ROOT_PATH] = &node{}
 
 	indexNode := &node{pattern: "index", part: "index"}
 	r.roots[ROOT_PATH].children = append(r.roots[ROOT_PATH].children, indexNode)
-	r.handlers["GET-"+DefaultPart] = handleIndexFunc
+	r.addRoute(indexNode, &Route{Method: "GET", Pattern: DefaultPart, Handler: handleIndexFunc})
 	return r
 }
 
@@ -76,7 +108,7 @@ func parsePattern(pattern string) []string {
 }
 
 // 将URL的字符串进行切割，分块保存到前缀树上
-func (r *Router) AddRouter(method string, pattern string, handler wcontext.HandleFunc, handleChain ...MiddlewareHandleFunc) {
+func (r *Router) AddRouter(method string, pattern string, handler wcontext.HandleFunc, handleChain ...MiddlewareHandleFunc) *Route {
 
 	// _, ok := r.roots[method]
 
@@ -87,48 +119,83 @@ func (r *Router) AddRouter(method string, pattern string, handler wcontext.Handl
 
 	parts := parsePattern(pattern)
 
+	// 根路由与默认首页使用同一个节点
+	if len(parts) == 1 && parts[0] == "" {
+		parts = []string{DefaultPart}
+	}
+
 	//前缀树节点插入
 	// r.roots[method].insert(pattern, parts, 0)
-	re := r.roots[ROOT_PATH].insert(pattern, parts, 0)
+	n := r.roots[ROOT_PATH].insert(pattern, parts, 0)
 
-	if !re {
+	if n == nil {
 		log.Panicln("{ ", pattern, " } register failed")
 	}
 
-	key := fmt.Sprintf("%s-%s", method, pattern)
-	r.handlers[key] = handler
+	route := &Route{Method: method, Pattern: pattern, Handler: handler, Chain: handleChain}
+	r.addRoute(n, route)
+	return route
+}
+
+func (r *Router) addRoute(n *node, route *Route) {
+	if n.routes == nil {
+		n.routes = make(map[string]*Route)
+	}
+	// 重复注册时覆盖
+	if old, ok := n.routes[route.Method]; ok {
+		for idx, existing := range r.routes {
+			if existing == old {
+				r.routes = append(r.routes[:idx], r.routes[idx+1:]...)
+				break
+			}
+		}
+	}
+	route.handle = route.compose()
+	n.routes[route.Method] = route
+	r.routes = append(r.routes, route)
+}
 
-	r.handleChains[key] = handleChain
+// 所有注册的路由（按注册顺序）
+func (r *Router) Routes() []*Route {
+	return append([]*Route(nil), r.routes...)
+}
 
+// 设置未匹配到路由时的处理函数
+func (r *Router) SetNotFound(handle wcontext.HandleFunc) {
+	r.notFound = handle
 }
+
+// 设置方法不支持时的处理函数
+func (r *Router) SetMethodNotAllowed(handle wcontext.HandleFunc) {
+	r.methodNotAllowed = handle
+}
+
+// 匹配路由，返回编译好的处理链
 func (r *Router) GetRouter(ctx *wcontext.Context) wcontext.HandleFunc {
 
-	n, params := r.getRouter(ctx.GetMethod(), ctx.Pattern)
+	n := r.matchRouter(ctx.Pattern)
 
-	ctx.Params = params
+	// 复用上下文中的map，匹配成功时用路由参数替换其中的内容
+	for key := range ctx.Params {
+		delete(ctx.Params, key)
+	}
 
 	// no matched
 	if n == nil {
-		return wcontext.HandleNotFound()
+		return r.notFound
 	}
+	fillParams(ctx.Params, n.pattern, ctx.Pattern)
 
-	key := fmt.Sprintf("%s-%s", ctx.GetMethod(), n.pattern)
-
-	if fn, ok := r.handlers[key]; ok {
-
-		handleChain, _ := r.handleChains[key]
-
-		for i := len(handleChain) - 1; i >= 0; i-- {
-			fn = handleChain[i](fn)
-		}
-		return fn
+	if route, ok := n.routes[ctx.GetMethod()]; ok {
+		ctx.SetRoutePattern(route.Pattern)
+		return route.handle
 	}
 
-	return wcontext.HandleMethodNotAllowed()
+	return r.methodNotAllowed
 }
 
-// 获取pattern和query参数
-func (r *Router) getRouter(method string, pattern string) (*node, map[string]string) {
+// 匹配前缀树节点
+func (r *Router) matchRouter(pattern string) *node {
 
 	parts := parsePattern(pattern)
 
@@ -142,7 +209,7 @@ func (r *Router) getRouter(method string, pattern string) (*node, map[string]str
 
 	// 不存在根路径，路由初始化失败
 	if !ok {
-		return nil, nil
+		return nil
 	}
 
 	// 匹配根路由
@@ -151,21 +218,18 @@ func (r *Router) getRouter(method string, pattern string) (*node, map[string]str
 	}
 
 	//从顶层开始匹配
-	n := root.search(parts, 0)
-
-	//匹配成功，解析参数
-	if n != nil {
-
-		return n, GetParams(n.pattern, pattern)
-	}
-
-	return nil, nil
-
+	return root.search(parts, 0)
 }
 
 // 第一个参数为服务段定义的路由，第二个参数为客户端传入
 func GetParams(pattern string, URL string) map[string]string {
 	params := make(map[string]string)
+	fillParams(params, pattern, URL)
+	return params
+}
+
+// 将解析出的参数写入params
+func fillParams(params map[string]string, pattern string, URL string) {
 
 	formatParts := parsePattern(pattern)
 
@@ -174,6 +238,9 @@ func GetParams(pattern string, URL string) map[string]string {
 	rcSize := len(parseParts)
 
 	for idx, part := range formatParts {
+		if part == "" {
+			continue
+		}
 		// 匹配 /a/:b模式
 		if part[0] == ':' {
 			// params[part[1:]] = idx< parseParts[idx]
@@ -191,8 +258,6 @@ func GetParams(pattern string, URL string) map[string]string {
 		}
 
 	}
-
-	return params
 }
 
 // 前缀树/搜索树
@@ -215,6 +280,9 @@ type node struct {
 
 	//匹配模式
 	useReg bool
+
+	// 以该节点为终点的路由，按请求方法区分
+	routes map[string]*Route
 }
 
 //功能
@@ -231,8 +299,8 @@ func (n *node) matchChild(part string) *node {
 	return nil
 }
 
-// 按层进行递归查找父节点，在父节点后插入
-func (n *node) insert(pattern string, parts []string, height int) bool {
+// 按层进行递归查找父节点，在父节点后插入，返回路由终点节点，冲突时返回nil
+func (n *node) insert(pattern string, parts []string, height int) *node {
 
 	//匹配完成，退出递归
 	if len(parts) == height {
@@ -241,7 +309,7 @@ func (n *node) insert(pattern string, parts []string, height int) bool {
 
 		// log.Println("INSERT COMPLETE: ", n)
 
-		return true
+		return n
 	}
 	//获取当前层的part
 	part := parts[height]
@@ -264,12 +332,15 @@ func (n *node) insert(pattern string, parts []string, height int) bool {
 
 		// 当前通配符路由，已存在参数路由
 		if n.paramNode != nil {
-			return false
+			return nil
 		}
 
-		//已存在通配符路由
+		//已存在通配符路由，part相同时为同一路由的其他请求方法
 		if n.regNode != nil {
-			return false
+			if n.regNode.part == part {
+				return n.regNode.insert(pattern, parts, height+1)
+			}
+			return nil
 		}
 
 		//通配符路由设置
@@ -278,7 +349,7 @@ func (n *node) insert(pattern string, parts []string, height int) bool {
 	case PPNODE:
 		// 当前参数路由，已存在统配路由
 		if n.regNode != nil {
-			return false
+			return nil
 		}
 
 		// 当前参数节点为空，直接创建该参数节点
@@ -291,7 +362,7 @@ func (n *node) insert(pattern string, parts []string, height int) bool {
 		}
 
 		// 存在参数节点，但与当前的参数part不一样，冲突路由，不能再注册
-		return false
+		return nil
 
 	case RNODE:
 		n.children = append(n.children, tmpNode)
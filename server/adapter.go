@@ -0,0 +1,57 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/asxlwsl/weber/middleware"
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 将net/http的Handler转换为处理函数
+// handler中可以通过wcontext.FromRequest(r)取回上下文
+//
+//	srv.GET("/metrics", server.WrapHandler(promhttp.Handler()))
+func WrapHandler(handler http.Handler) HandleFunc {
+	return func(ctx *wcontext.Context) {
+		ctx.ServeThrough(handler)
+	}
+}
+
+// 将net/http风格的中间件转换为中间件
+// 中间件对请求的修改（如r.WithContext）对后续的处理函数可见，对ResponseWriter的包装（如压缩、记录状态码）对后续写出的响应生效
+// 包装了ResponseWriter时，后续的处理链返回后响应立即写出，外层的中间件不能再修改响应
+//
+//	srv.Use(server.WrapMiddleware(handlers.CompressHandler))
+func WrapMiddleware(mw func(http.Handler) http.Handler) MiddlewareHandleFunc {
+	return func(next HandleFunc) HandleFunc {
+		handler := mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := wcontext.FromRequest(r)
+			if ctx == nil {
+				// 中间件丢弃了原请求的context，无法继续处理
+				http.Error(w, "weber: request context lost in net/http middleware", http.StatusInternalServerError)
+				return
+			}
+			ctx.ServeWith(w, r, next)
+		}))
+		return func(ctx *wcontext.Context) {
+			ctx.ServeThrough(handler)
+		}
+	}
+}
+
+// 将处理函数和中间件导出为net/http的Handler，可以挂载到http.ServeMux或其他框架上
+// 不包含错误恢复等全局中间件，需要时作为middlewares传入
+// 在WrapHandler、WrapMiddleware中调用时，会继承外层上下文中通过Set保存的数据
+//
+//	mux.Handle("/api/", server.HTTPHandler(api, middleware.Recovery()))
+func HTTPHandler(handler HandleFunc, middlewares ...MiddlewareHandleFunc) http.Handler {
+	chain := buildChain(append([]MiddlewareHandleFunc{middleware.Flush()}, middlewares...), handler)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := wcontext.AcquireContext(w, r)
+		defer wcontext.ReleaseContext(ctx)
+		if parent := wcontext.FromRequest(r); parent != nil {
+			ctx.CopyValues(parent)
+		}
+		chain(ctx)
+	})
+}
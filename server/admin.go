@@ -0,0 +1,314 @@
+package server
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"reflect"
+	"runtime"
+	"runtime/debug"
+	rpprof "runtime/pprof"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 管理接口默认的路由前缀
+const DefaultAdminPrefix = "/debug"
+
+// 管理接口的访问控制，返回false时响应403
+type AdminGuard func(ctx *wcontext.Context) bool
+
+type adminConfig struct {
+	prefix string
+	guard  AdminGuard
+
+	// 不为空时在单独的监听地址上提供管理接口
+	addr string
+}
+
+type AdminOption func(config *adminConfig)
+
+// 管理接口的路由前缀，默认为/debug
+func WithAdminPrefix(prefix string) AdminOption {
+	return func(config *adminConfig) {
+		config.prefix = prefix
+	}
+}
+
+// 替换访问控制，默认只允许本机访问
+func WithAdminGuard(guard AdminGuard) AdminOption {
+	return func(config *adminConfig) {
+		config.guard = guard
+	}
+}
+
+// 在单独的地址上提供管理接口（如127.0.0.1:6060），主服务监听成功后启动，随主服务关闭
+func WithAdminAddr(addr string) AdminOption {
+	return func(config *adminConfig) {
+		config.addr = addr
+	}
+}
+
+// 只允许来自本机回环地址的请求，不信任X-Forwarded-For等请求头
+func AdminLocalOnly() AdminGuard {
+	return func(ctx *wcontext.Context) bool {
+		host, _, err := net.SplitHostPort(ctx.Request().RemoteAddr)
+		if err != nil {
+			host = ctx.Request().RemoteAddr
+		}
+		ip := net.ParseIP(host)
+		return ip != nil && ip.IsLoopback()
+	}
+}
+
+// 要求请求头携带 Authorization: Bearer <token>
+func AdminToken(token string) AdminGuard {
+	expected := []byte("Bearer " + token)
+	return func(ctx *wcontext.Context) bool {
+		actual := []byte(ctx.Request().Header.Get("Authorization"))
+		return subtle.ConstantTimeCompare(actual, expected) == 1
+	}
+}
+
+// 挂载管理接口：pprof、协程栈、路由表、构建信息、expvar、运行时状态和中间件配置
+// 返回管理接口所在的路由组，可以继续注册其他管理路由
+//
+//	srv.EnableAdmin(server.WithAdminAddr("127.0.0.1:6060"))
+//	srv.EnableAdmin(server.WithAdminPrefix("/admin"), server.WithAdminGuard(server.AdminToken(token)))
+//
+// 路由：
+//
+//	GET {prefix}/pprof                 profile列表
+//	GET {prefix}/pprof/:name           heap、allocs、goroutine、block、mutex、threadcreate，以及cmdline、profile、symbol、trace
+//	GET {prefix}/goroutines            所有协程的调用栈
+//	GET {prefix}/routes                路由表
+//	GET {prefix}/buildinfo             构建信息
+//	GET {prefix}/vars                  expvar
+//	GET {prefix}/runtime               内存、GC、协程数量
+//	GET {prefix}/middlewares           当前生效的中间件
+func (h *HttpServer) EnableAdmin(options ...AdminOption) *RouterGroup {
+	config := &adminConfig{prefix: DefaultAdminPrefix, guard: AdminLocalOnly()}
+	for _, option := range options {
+		option(config)
+	}
+
+	engine := h
+	if config.addr != "" {
+		engine = NewHttpServer(WithShutdownTimeout(h.shutdownTimeout), WithLogger(h.logger.With("server", "admin")))
+		engine.internal = true
+		// 主服务监听成功后才启动，避免主服务启动失败时管理端（pprof）仍在运行
+		// 每个监听都会执行OnReady，管理端只启动一次
+		var once sync.Once
+		h.OnReady(func(addr net.Addr) (err error) {
+			once.Do(func() {
+				err = engine.Start(config.addr)
+			})
+			return err
+		})
+		h.OnShutdown(engine.Shutdown)
+	}
+
+	group := engine.Group(config.prefix)
+	if config.guard != nil {
+		group.Use(adminGuard(config.guard))
+	}
+
+	admin := &admin{server: h, prefix: group.prefix}
+	group.GET("/pprof", admin.pprofIndex)
+	group.GET("/pprof/cmdline", WrapHandler(http.HandlerFunc(pprof.Cmdline)))
+	group.GET("/pprof/profile", WrapHandler(http.HandlerFunc(pprof.Profile)))
+	group.GET("/pprof/symbol", WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.POST("/pprof/symbol", WrapHandler(http.HandlerFunc(pprof.Symbol)))
+	group.GET("/pprof/trace", WrapHandler(http.HandlerFunc(pprof.Trace)))
+	group.GET("/pprof/:name", admin.pprofProfile)
+	group.GET("/goroutines", admin.goroutines)
+	group.GET("/routes", admin.routes)
+	group.GET("/buildinfo", admin.buildInfo)
+	group.GET("/vars", WrapHandler(expvar.Handler()))
+	group.GET("/runtime", admin.runtimeStats)
+	group.GET("/middlewares", admin.middlewares)
+	return group
+}
+
+func adminGuard(guard AdminGuard) MiddlewareHandleFunc {
+	return func(next HandleFunc) HandleFunc {
+		return func(ctx *wcontext.Context) {
+			if !guard(ctx) {
+				ctx.Fail(http.StatusForbidden, "403 Forbidden!")
+				return
+			}
+			next(ctx)
+		}
+	}
+}
+
+type admin struct {
+	server *HttpServer
+	prefix string
+}
+
+func (a *admin) pprofIndex(ctx *wcontext.Context) {
+	var builder strings.Builder
+	builder.WriteString("<html><head><title>pprof</title></head><body><table>\n")
+	for _, profile := range rpprof.Profiles() {
+		fmt.Fprintf(&builder, "<tr><td>%d</td><td><a href=\"%s/pprof/%s?debug=1\">%s</a></td></tr>\n",
+			profile.Count(), a.prefix, profile.Name(), profile.Name())
+	}
+	fmt.Fprintf(&builder, "<tr><td></td><td><a href=\"%s/pprof/profile?seconds=30\">profile</a></td></tr>\n", a.prefix)
+	fmt.Fprintf(&builder, "<tr><td></td><td><a href=\"%s/pprof/trace?seconds=5\">trace</a></td></tr>\n", a.prefix)
+	builder.WriteString("</table></body></html>")
+	ctx.HTML(builder.String())
+}
+
+func (a *admin) pprofProfile(ctx *wcontext.Context) {
+	name, _ := ctx.GetParam("name")
+	if rpprof.Lookup(name) == nil {
+		ctx.Fail(http.StatusNotFound, "unknown profile")
+		return
+	}
+	pprof.Handler(name).ServeHTTP(ctx.Writer(), ctx.Request())
+}
+
+func (a *admin) goroutines(ctx *wcontext.Context) {
+	ctx.SetResponseHeader(wcontext.CONTENT_TYPE, "text/plain; charset=utf-8")
+	rpprof.Lookup("goroutine").WriteTo(ctx.Writer(), 2)
+}
+
+func (a *admin) routes(ctx *wcontext.Context) {
+	ctx.JSON(a.server.RouteTable())
+}
+
+type adminModule struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	Sum     string `json:"sum,omitempty"`
+	Replace string `json:"replace,omitempty"`
+}
+
+func (a *admin) buildInfo(ctx *wcontext.Context) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		ctx.Fail(http.StatusNotFound, "build info not available")
+		return
+	}
+
+	module := func(m *debug.Module) adminModule {
+		result := adminModule{Path: m.Path, Version: m.Version, Sum: m.Sum}
+		if m.Replace != nil {
+			result.Replace = m.Replace.Path + " " + m.Replace.Version
+		}
+		return result
+	}
+	deps := make([]adminModule, 0, len(info.Deps))
+	for _, dep := range info.Deps {
+		deps = append(deps, module(dep))
+	}
+	settings := make(map[string]string, len(info.Settings))
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+
+	ctx.JSON(wcontext.H{
+		"go_version": info.GoVersion,
+		"path":       info.Path,
+		"main":       module(&info.Main),
+		"deps":       deps,
+		"settings":   settings,
+	})
+}
+
+// 服务启动时间，用于计算运行时长
+var processStart = time.Now()
+
+func (a *admin) runtimeStats(ctx *wcontext.Context) {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+
+	ctx.JSON(wcontext.H{
+		"uptime":     time.Since(processStart).String(),
+		"goroutines": runtime.NumGoroutine(),
+		"cpus":       runtime.NumCPU(),
+		"gomaxprocs": runtime.GOMAXPROCS(0),
+		"ready":      a.server.Ready(),
+		"memory": wcontext.H{
+			"alloc":        mem.Alloc,
+			"total_alloc":  mem.TotalAlloc,
+			"sys":          mem.Sys,
+			"heap_alloc":   mem.HeapAlloc,
+			"heap_inuse":   mem.HeapInuse,
+			"heap_objects": mem.HeapObjects,
+			"stack_inuse":  mem.StackInuse,
+		},
+		"gc": wcontext.H{
+			"num_gc":         mem.NumGC,
+			"pause_total_ns": mem.PauseTotalNs,
+			"last_gc":        time.Unix(0, int64(mem.LastGC)),
+			"next_gc":        mem.NextGC,
+		},
+	})
+}
+
+func (a *admin) middlewares(ctx *wcontext.Context) {
+	h := a.server
+
+	groups := make(map[string][]string)
+	for _, group := range append([]*RouterGroup{h.RouterGroup}, h.groups...) {
+		if names := funcNames(group.middlewares); len(names) > 0 {
+			prefix := group.prefix
+			if prefix == "" {
+				prefix = "/"
+			}
+			groups[prefix] = names
+		}
+	}
+
+	// 配置文件中的选项可能包含密钥等敏感信息，只返回名称
+	configured := make([]string, 0, len(h.configMiddlewares))
+	for _, mid := range h.configMiddlewares {
+		configured = append(configured, mid.Name)
+	}
+
+	ctx.JSON(wcontext.H{
+		"global":     funcNames(h.globalMiddlewares()),
+		"groups":     groups,
+		"configured": configured,
+	})
+}
+
+// 函数名，去掉闭包的后缀，例如 github.com/asxlwsl/weber/middleware.Logger.func1 -> middleware.Logger
+func funcName(fn any) string {
+	value := reflect.ValueOf(fn)
+	if value.Kind() != reflect.Func || value.IsNil() {
+		return ""
+	}
+	f := runtime.FuncForPC(value.Pointer())
+	if f == nil {
+		return ""
+	}
+	name := f.Name()
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	for {
+		idx := strings.LastIndex(name, ".func")
+		if idx < 0 {
+			break
+		}
+		name = name[:idx]
+	}
+	return strings.TrimSuffix(name, "-fm")
+}
+
+func funcNames(middlewares []MiddlewareHandleFunc) []string {
+	names := make([]string, 0, len(middlewares))
+	for _, mw := range middlewares {
+		names = append(names, funcName(mw))
+	}
+	return names
+}
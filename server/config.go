@@ -0,0 +1,432 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/asxlwsl/weber/middleware"
+)
+
+// 声明式配置
+// 从JSON或TOML文件加载，再由WEBER_*环境变量覆盖，例如：
+//
+//	WEBER_ADDR=:9090
+//	WEBER_TIMEOUTS_READ_HEADER=5s
+//	WEBER_MIDDLEWARES=logger,request_filter
+//	WEBER_STATIC=/assets=./public,/img=./img
+
+// 环境变量前缀
+const ENV_PREFIX = "WEBER_"
+
+type Config struct {
+	// 监听地址
+	Addr string `json:"addr"`
+
+	TLS TLSConfig `json:"tls"`
+
+	Timeouts TimeoutConfig `json:"timeouts"`
+
+	// 请求头的最大字节数
+	MaxHeaderBytes int `json:"max_header_bytes"`
+
+	// 按顺序启用的内置中间件
+	Middlewares []MiddlewareConfig `json:"middlewares"`
+
+	// 静态资源目录
+	Static []StaticConfig `json:"static"`
+
+	// 框架日志级别：debug、info、warn、error
+	LogLevel string `json:"log_level"`
+}
+
+type TLSConfig struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+type TimeoutConfig struct {
+	Read       Duration `json:"read"`
+	ReadHeader Duration `json:"read_header"`
+	Write      Duration `json:"write"`
+	Idle       Duration `json:"idle"`
+
+	// 优雅关闭时排空请求的超时时间
+	Shutdown Duration `json:"shutdown"`
+
+	// 就绪状态切换后等待负载均衡摘除流量的时间
+	Drain Duration `json:"drain"`
+}
+
+type MiddlewareConfig struct {
+	// 通过middleware.Register注册的名称
+	Name string `json:"name"`
+
+	Options map[string]string `json:"options"`
+}
+
+type StaticConfig struct {
+	Prefix string `json:"prefix"`
+	Dir    string `json:"dir"`
+}
+
+// 配置中的时间，支持"5s"这样的字符串或表示秒数的数字
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value any
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	switch v := value.(type) {
+	case float64:
+		*d = Duration(v * float64(time.Second))
+		return nil
+	case string:
+		duration, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*d = Duration(duration)
+		return nil
+	}
+	return fmt.Errorf("invalid duration %s", data)
+}
+
+// 配置错误，Key指向出错的配置项（文件中的键路径或环境变量名）
+type ConfigError struct {
+	Key string
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	if e.Key == "" {
+		return fmt.Sprintf("config: %v", e.Err)
+	}
+	return fmt.Sprintf("config %s: %v", e.Key, e.Err)
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// 默认配置，与NewHttpServer的默认值一致
+func DefaultConfig() *Config {
+	return &Config{
+		Addr: ":8080",
+		Timeouts: TimeoutConfig{
+			Read:       Duration(DefaultReadTimeout),
+			ReadHeader: Duration(DefaultReadHeaderTimeout),
+			Write:      Duration(DefaultWriteTimeout),
+			Idle:       Duration(DefaultIdleTimeout),
+			Shutdown:   Duration(DefaultShutdownTimeout),
+		},
+		MaxHeaderBytes: DefaultMaxHeaderBytes,
+		LogLevel:       "info",
+	}
+}
+
+// 加载配置文件（按扩展名识别.json或.toml），再应用环境变量并校验
+// path为空时只使用默认值和环境变量
+func LoadConfig(path string) (*Config, error) {
+	config := DefaultConfig()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+		case ".toml":
+			tree, err := parseTOML(data)
+			if err != nil {
+				return nil, err
+			}
+			if data, err = json.Marshal(tree); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &ConfigError{Err: fmt.Errorf("unsupported config format %q", filepath.Ext(path))}
+		}
+
+		if err := decodeConfig(data, config); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := config.applyEnv(os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// 中间件创建失败时的错误，键指向未知的名称或出错的选项
+func middlewareConfigError(idx int, err error) error {
+	key := fmt.Sprintf("middlewares[%d]", idx)
+	var optionErr *middleware.OptionError
+	switch {
+	case errors.As(err, &optionErr):
+		return &ConfigError{Key: fmt.Sprintf("%s.options.%s", key, optionErr.Key), Err: optionErr.Err}
+	case errors.Is(err, middleware.UNKNOWN_MIDDLEWARE):
+		return &ConfigError{Key: key + ".name", Err: err}
+	}
+	return &ConfigError{Key: key, Err: err}
+}
+
+func decodeConfig(data []byte, config *Config) error {
+	// 先按通用结构解析，检查未知的键，保证错误信息带有完整的键路径
+	var tree any
+	if err := json.Unmarshal(data, &tree); err != nil {
+		return &ConfigError{Err: err}
+	}
+	if err := checkKeys(tree, reflect.TypeOf(config).Elem(), ""); err != nil {
+		return err
+	}
+
+	err := json.Unmarshal(data, config)
+	if err == nil {
+		return nil
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return &ConfigError{Key: typeErr.Field, Err: fmt.Errorf("expected %s", typeErr.Type)}
+	}
+	return &ConfigError{Err: err}
+}
+
+// 对照结构体的json标签检查未知的键和时间格式
+func checkKeys(value any, typ reflect.Type, path string) error {
+	if typ == reflect.TypeOf(Duration(0)) {
+		data, _ := json.Marshal(value)
+		var duration Duration
+		if err := duration.UnmarshalJSON(data); err != nil {
+			return &ConfigError{Key: path, Err: err}
+		}
+		return nil
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if typ.Kind() != reflect.Struct {
+			return nil
+		}
+		fields := make(map[string]reflect.Type)
+		for idx := 0; idx < typ.NumField(); idx++ {
+			field := typ.Field(idx)
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			fields[name] = field.Type
+		}
+		for key, child := range v {
+			childPath := key
+			if path != "" {
+				childPath = path + "." + key
+			}
+			fieldType, ok := fields[key]
+			if !ok {
+				return &ConfigError{Key: childPath, Err: errors.New("unknown key")}
+			}
+			if err := checkKeys(child, fieldType, childPath); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if typ.Kind() != reflect.Slice {
+			return nil
+		}
+		for idx, child := range v {
+			if err := checkKeys(child, typ.Elem(), fmt.Sprintf("%s[%d]", path, idx)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// 应用WEBER_*环境变量
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	strs := map[string]*string{
+		"ADDR":          &c.Addr,
+		"TLS_CERT_FILE": &c.TLS.CertFile,
+		"TLS_KEY_FILE":  &c.TLS.KeyFile,
+		"LOG_LEVEL":     &c.LogLevel,
+	}
+	for name, field := range strs {
+		if value, ok := lookup(ENV_PREFIX + name); ok {
+			*field = value
+		}
+	}
+
+	durations := map[string]*Duration{
+		"TIMEOUTS_READ":        &c.Timeouts.Read,
+		"TIMEOUTS_READ_HEADER": &c.Timeouts.ReadHeader,
+		"TIMEOUTS_WRITE":       &c.Timeouts.Write,
+		"TIMEOUTS_IDLE":        &c.Timeouts.Idle,
+		"TIMEOUTS_SHUTDOWN":    &c.Timeouts.Shutdown,
+		"TIMEOUTS_DRAIN":       &c.Timeouts.Drain,
+	}
+	for name, field := range durations {
+		if value, ok := lookup(ENV_PREFIX + name); ok {
+			duration, err := time.ParseDuration(value)
+			if err != nil {
+				return &ConfigError{Key: ENV_PREFIX + name, Err: err}
+			}
+			*field = Duration(duration)
+		}
+	}
+
+	if value, ok := lookup(ENV_PREFIX + "MAX_HEADER_BYTES"); ok {
+		size, err := strconv.Atoi(value)
+		if err != nil {
+			return &ConfigError{Key: ENV_PREFIX + "MAX_HEADER_BYTES", Err: err}
+		}
+		c.MaxHeaderBytes = size
+	}
+
+	// 逗号分隔的中间件名称，覆盖文件中的配置
+	if value, ok := lookup(ENV_PREFIX + "MIDDLEWARES"); ok {
+		c.Middlewares = nil
+		for _, name := range splitList(value) {
+			c.Middlewares = append(c.Middlewares, MiddlewareConfig{Name: name})
+		}
+	}
+
+	// 逗号分隔的 前缀=目录，覆盖文件中的配置
+	if value, ok := lookup(ENV_PREFIX + "STATIC"); ok {
+		c.Static = nil
+		for _, mount := range splitList(value) {
+			prefix, dir, found := strings.Cut(mount, "=")
+			if !found {
+				return &ConfigError{Key: ENV_PREFIX + "STATIC", Err: fmt.Errorf("expected prefix=dir, got %q", mount)}
+			}
+			c.Static = append(c.Static, StaticConfig{Prefix: prefix, Dir: dir})
+		}
+	}
+	return nil
+}
+
+func splitList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// 校验配置
+func (c *Config) Validate() error {
+	if c.Addr == "" {
+		return &ConfigError{Key: "addr", Err: errors.New("must not be empty")}
+	}
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		key := "tls.key_file"
+		if c.TLS.CertFile == "" {
+			key = "tls.cert_file"
+		}
+		return &ConfigError{Key: key, Err: errors.New("cert_file and key_file must be set together")}
+	}
+
+	timeouts := []struct {
+		key   string
+		value Duration
+	}{
+		{"timeouts.read", c.Timeouts.Read},
+		{"timeouts.read_header", c.Timeouts.ReadHeader},
+		{"timeouts.write", c.Timeouts.Write},
+		{"timeouts.idle", c.Timeouts.Idle},
+		{"timeouts.shutdown", c.Timeouts.Shutdown},
+		{"timeouts.drain", c.Timeouts.Drain},
+	}
+	for _, timeout := range timeouts {
+		if timeout.value < 0 {
+			return &ConfigError{Key: timeout.key, Err: errors.New("must not be negative")}
+		}
+	}
+	if c.MaxHeaderBytes < 0 {
+		return &ConfigError{Key: "max_header_bytes", Err: errors.New("must not be negative")}
+	}
+
+	for idx, mid := range c.Middlewares {
+		if _, err := middleware.Build(mid.Name, mid.Options); err != nil {
+			return middlewareConfigError(idx, err)
+		}
+	}
+
+	for idx, static := range c.Static {
+		if !strings.HasPrefix(static.Prefix, "/") {
+			return &ConfigError{Key: fmt.Sprintf("static[%d].prefix", idx), Err: errors.New("must start with /")}
+		}
+		if info, err := os.Stat(static.Dir); err != nil || !info.IsDir() {
+			return &ConfigError{Key: fmt.Sprintf("static[%d].dir", idx), Err: fmt.Errorf("%q isn't a directory", static.Dir)}
+		}
+	}
+
+	switch c.LogLevel {
+	case "debug", "info", "warn", "error":
+	default:
+		return &ConfigError{Key: "log_level", Err: fmt.Errorf("unknown level %q", c.LogLevel)}
+	}
+	return nil
+}
+
+// 转换为HttpOption
+func (c *Config) Options() []HttpOption {
+	options := []HttpOption{
+		WithReadTimeout(time.Duration(c.Timeouts.Read)),
+		WithReadHeaderTimeout(time.Duration(c.Timeouts.ReadHeader)),
+		WithWriteTimeout(time.Duration(c.Timeouts.Write)),
+		WithIdleTimeout(time.Duration(c.Timeouts.Idle)),
+		WithShutdownTimeout(time.Duration(c.Timeouts.Shutdown)),
+		WithDrainDelay(time.Duration(c.Timeouts.Drain)),
+		WithMaxHeaderBytes(c.MaxHeaderBytes),
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.LogLevel)); err == nil {
+		options = append(options, WithLogLevel(level))
+	}
+	if c.TLS.CertFile != "" {
+		options = append(options, WithTLS(c.TLS.CertFile, c.TLS.KeyFile))
+	}
+	return options
+}
+
+// 根据配置创建服务，options在配置之后应用，可以覆盖配置项
+// 启动时使用config.Addr：srv.Run(config.Addr)
+func NewHttpServerFromConfig(config *Config, options ...HttpOption) (*HttpServer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	server := NewHttpServer(append(config.Options(), options...)...)
+
+	for idx, mid := range config.Middlewares {
+		handler, err := middleware.Build(mid.Name, mid.Options)
+		if err != nil {
+			return nil, middlewareConfigError(idx, err)
+		}
+		server.Use(handler)
+		server.configMiddlewares = append(server.configMiddlewares, mid)
+	}
+
+	for _, static := range config.Static {
+		server.Static(static.Prefix, static.Dir)
+	}
+	return server, nil
+}
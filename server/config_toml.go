@@ -0,0 +1,252 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 解析TOML的常用子集：
+//   - key = value，支持 a.b = value 形式的点分键
+//   - [table] 与 [a.b] 子表
+//   - [[array]] 表数组，之后的 [array.sub] 指向数组最后一个元素
+//   - 字符串（"基本" 与 '字面'）、整数、浮点数、布尔值和单行数组
+//   - # 注释
+func parseTOML(data []byte) (map[string]any, error) {
+	root := map[string]any{}
+	current := root
+	currentPath := ""
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		lineErr := func(key string, err error) error {
+			return &ConfigError{Key: key, Err: fmt.Errorf("line %d: %v", lineNo, err)}
+		}
+
+		// 表数组
+		if strings.HasPrefix(line, "[[") {
+			if !strings.HasSuffix(line, "]]") {
+				return nil, lineErr("", fmt.Errorf("unterminated table header %q", line))
+			}
+			currentPath = strings.TrimSpace(line[2 : len(line)-2])
+			keys, err := splitKey(currentPath)
+			if err != nil {
+				return nil, lineErr(currentPath, err)
+			}
+			parent, err := resolveTable(root, keys[:len(keys)-1])
+			if err != nil {
+				return nil, lineErr(currentPath, err)
+			}
+			last := keys[len(keys)-1]
+			var array []any
+			if existing, ok := parent[last]; ok {
+				if array, ok = existing.([]any); !ok {
+					return nil, lineErr(currentPath, fmt.Errorf("%q is already defined", last))
+				}
+			}
+			current = map[string]any{}
+			parent[last] = append(array, current)
+			continue
+		}
+
+		// 表
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, lineErr("", fmt.Errorf("unterminated table header %q", line))
+			}
+			currentPath = strings.TrimSpace(line[1 : len(line)-1])
+			keys, err := splitKey(currentPath)
+			if err != nil {
+				return nil, lineErr(currentPath, err)
+			}
+			if current, err = resolveTable(root, keys); err != nil {
+				return nil, lineErr(currentPath, err)
+			}
+			continue
+		}
+
+		// 键值对
+		idx := strings.IndexRune(line, '=')
+		if idx == -1 {
+			return nil, lineErr("", fmt.Errorf("expected key = value, got %q", line))
+		}
+		rawKey := strings.TrimSpace(line[:idx])
+		fullKey := rawKey
+		if currentPath != "" {
+			fullKey = currentPath + "." + rawKey
+		}
+
+		keys, err := splitKey(rawKey)
+		if err != nil {
+			return nil, lineErr(fullKey, err)
+		}
+		value, err := parseTOMLValue(strings.TrimSpace(line[idx+1:]))
+		if err != nil {
+			return nil, lineErr(fullKey, err)
+		}
+		table, err := resolveTable(current, keys[:len(keys)-1])
+		if err != nil {
+			return nil, lineErr(fullKey, err)
+		}
+		last := keys[len(keys)-1]
+		if _, ok := table[last]; ok {
+			return nil, lineErr(fullKey, fmt.Errorf("duplicate key"))
+		}
+		table[last] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ConfigError{Err: err}
+	}
+	return root, nil
+}
+
+// 去掉引号之外的 # 注释
+func stripComment(line string) string {
+	var quote byte
+	escaped := false
+	for idx := 0; idx < len(line); idx++ {
+		ch := line[idx]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && ch == '\\':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == '#':
+			return line[:idx]
+		}
+	}
+	return line
+}
+
+// 拆分点分键，只支持裸键（字母、数字、_和-）
+func splitKey(key string) ([]string, error) {
+	parts := strings.Split(key, ".")
+	for idx, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("invalid key %q", key)
+		}
+		for _, ch := range part {
+			if !(ch == '_' || ch == '-' || ch >= '0' && ch <= '9' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z') {
+				return nil, fmt.Errorf("invalid key %q", key)
+			}
+		}
+		parts[idx] = part
+	}
+	return parts, nil
+}
+
+// 按键路径查找（必要时创建）子表，路径上的表数组取最后一个元素
+func resolveTable(table map[string]any, keys []string) (map[string]any, error) {
+	for _, key := range keys {
+		switch value := table[key].(type) {
+		case nil:
+			next := map[string]any{}
+			table[key] = next
+			table = next
+		case map[string]any:
+			table = value
+		case []any:
+			if len(value) == 0 {
+				return nil, fmt.Errorf("%q is an empty array", key)
+			}
+			last, ok := value[len(value)-1].(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%q isn't a table", key)
+			}
+			table = last
+		default:
+			return nil, fmt.Errorf("%q isn't a table", key)
+		}
+	}
+	return table, nil
+}
+
+func parseTOMLValue(raw string) (any, error) {
+	switch {
+	case raw == "":
+		return nil, fmt.Errorf("missing value")
+	case raw[0] == '"':
+		return strconv.Unquote(raw)
+	case raw[0] == '\'':
+		if len(raw) < 2 || raw[len(raw)-1] != '\'' {
+			return nil, fmt.Errorf("unterminated string %s", raw)
+		}
+		return raw[1 : len(raw)-1], nil
+	case raw[0] == '[':
+		if raw[len(raw)-1] != ']' {
+			return nil, fmt.Errorf("unterminated array %s", raw)
+		}
+		values := make([]any, 0)
+		for _, item := range splitArray(raw[1 : len(raw)-1]) {
+			value, err := parseTOMLValue(item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		return values, nil
+	case raw == "true":
+		return true, nil
+	case raw == "false":
+		return false, nil
+	}
+
+	number := strings.ReplaceAll(raw, "_", "")
+	if value, err := strconv.ParseInt(number, 10, 64); err == nil {
+		return value, nil
+	}
+	if value, err := strconv.ParseFloat(number, 64); err == nil {
+		return value, nil
+	}
+	return nil, fmt.Errorf("invalid value %s", raw)
+}
+
+// 按引号之外的逗号拆分数组元素
+func splitArray(raw string) []string {
+	items := make([]string, 0)
+	var quote byte
+	escaped := false
+	start := 0
+	for idx := 0; idx < len(raw); idx++ {
+		ch := raw[idx]
+		switch {
+		case escaped:
+			escaped = false
+		case quote == '"' && ch == '\\':
+			escaped = true
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '"' || ch == '\'':
+			quote = ch
+		case ch == ',':
+			items = append(items, raw[start:idx])
+			start = idx + 1
+		}
+	}
+	items = append(items, raw[start:])
+
+	// 允许末尾多一个逗号
+	result := make([]string, 0, len(items))
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
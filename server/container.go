@@ -0,0 +1,293 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"sync/atomic"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 服务的生命周期
+type Scope int
+
+const (
+	// 整个服务只创建一次，服务关闭时按创建的逆序关闭
+	Singleton Scope = iota
+
+	// 每个请求创建一次，请求结束时关闭
+	RequestScope
+)
+
+var (
+	SERVICE_NOT_FOUND = errors.New("service not provided")
+	SERVICE_CYCLE     = errors.New("service dependency cycle")
+	SCOPE_MISMATCH    = errors.New("request scoped service resolved outside a request")
+	CONTAINER_CLOSED  = errors.New("service container closed")
+)
+
+// 创建服务，ctx为请求的上下文，单例服务创建时为nil
+type ServiceFactory func(c *Container, ctx *wcontext.Context) (any, error)
+
+// 服务容器，以类型为键，见weber.Provide、weber.Resolve
+type Container struct {
+	*registry
+
+	// 当前正在创建的单例服务，用于检测循环依赖
+	resolving []reflect.Type
+
+	// 所属的获取过程，顶层的Resolve为nil
+	resolution *resolution
+}
+
+type registry struct {
+	mu        sync.RWMutex
+	providers map[reflect.Type]*provider
+
+	// 已创建的单例，按创建顺序
+	created []*provider
+
+	// 是否存在请求级服务，没有时请求结束不需要清理
+	hasRequestScope atomic.Bool
+
+	// 请求级服务的实例
+	scopeMu sync.Mutex
+	scopes  map[*wcontext.Context]*requestScope
+
+	closed bool
+}
+
+type provider struct {
+	key     reflect.Type
+	scope   Scope
+	factory ServiceFactory
+
+	// 以下字段由registry.mu保护，创建期间不持有锁，避免并发获取互相依赖的服务时死锁
+	done     bool
+	instance any
+
+	// 正在创建时非nil，创建结束后关闭
+	creating chan struct{}
+	owner    *resolution
+}
+
+// 一次单例的获取过程（顶层的Resolve及其依赖），用于检测并发获取之间的循环等待
+type resolution struct {
+	// 正在等待其他获取过程创建的服务
+	waiting *provider
+}
+
+type requestScope struct {
+	instances map[reflect.Type]any
+	creating  map[reflect.Type]bool
+
+	// 按创建顺序，请求结束时逆序关闭
+	order []any
+}
+
+func NewContainer() *Container {
+	return &Container{registry: &registry{
+		providers: make(map[reflect.Type]*provider),
+		scopes:    make(map[*wcontext.Context]*requestScope),
+	}}
+}
+
+// 服务容器
+// 服务关闭时在所有关闭钩子（包括模块的Stop）执行完后关闭其中的单例
+func (h *HttpServer) Services() *Container {
+	return h.container
+}
+
+// 注册服务，同一类型重复注册时覆盖（例如在测试中替换为模拟实现）
+func (c *Container) Provide(key reflect.Type, scope Scope, factory ServiceFactory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers[key] = &provider{key: key, scope: scope, factory: factory}
+	if scope == RequestScope {
+		c.hasRequestScope.Store(true)
+	}
+}
+
+// 获取服务，ctx为nil时只能获取单例服务
+func (c *Container) Resolve(ctx *wcontext.Context, key reflect.Type) (any, error) {
+	c.mu.RLock()
+	p, ok := c.providers[key]
+	c.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %v", SERVICE_NOT_FOUND, key)
+	}
+
+	if p.scope == RequestScope {
+		if ctx == nil {
+			return nil, fmt.Errorf("%w: %v", SCOPE_MISMATCH, key)
+		}
+		return c.resolveScoped(ctx, p)
+	}
+	return c.resolveSingleton(p)
+}
+
+func (c *Container) resolveSingleton(p *provider) (any, error) {
+	for _, key := range c.resolving {
+		if key == p.key {
+			return nil, fmt.Errorf("%w: %v", SERVICE_CYCLE, append(c.resolving, p.key))
+		}
+	}
+
+	current := c.resolution
+	if current == nil {
+		current = &resolution{}
+	}
+
+	c.mu.Lock()
+	for p.creating != nil && !p.done {
+		// 其他获取过程正在创建，等待的服务最终又在等待当前的获取过程时为循环依赖
+		if waitsFor(p.owner, current) {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("%w: %v", SERVICE_CYCLE, append(c.resolving, p.key))
+		}
+		creating := p.creating
+		current.waiting = p
+		c.mu.Unlock()
+		<-creating
+		c.mu.Lock()
+		current.waiting = nil
+	}
+	if p.done {
+		c.mu.Unlock()
+		return p.instance, nil
+	}
+	// 容器关闭后不再创建单例，否则新建的服务不会被关闭
+	if c.closed {
+		c.mu.Unlock()
+		return nil, fmt.Errorf("%w: %v", CONTAINER_CLOSED, p.key)
+	}
+	p.creating, p.owner = make(chan struct{}), current
+	c.mu.Unlock()
+
+	// 工厂函数通过带有依赖路径的容器获取其他服务
+	view := &Container{
+		registry:   c.registry,
+		resolving:  append(c.resolving[:len(c.resolving):len(c.resolving)], p.key),
+		resolution: current,
+	}
+	instance, err := p.factory(view, nil)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	close(p.creating)
+	p.creating, p.owner = nil, nil
+	if err != nil {
+		// 创建失败不缓存，下次获取时重试
+		return nil, err
+	}
+	if c.closed {
+		// 创建期间容器已关闭
+		return nil, errors.Join(fmt.Errorf("%w: %v", CONTAINER_CLOSED, p.key), closeService(instance))
+	}
+	p.instance, p.done = instance, true
+	c.created = append(c.created, p)
+	return instance, nil
+}
+
+// owner是否（间接）在等待target，调用时需持有registry.mu
+func waitsFor(owner *resolution, target *resolution) bool {
+	for owner != nil {
+		if owner == target {
+			return true
+		}
+		if owner.waiting == nil {
+			return false
+		}
+		owner = owner.waiting.owner
+	}
+	return false
+}
+
+func (c *Container) resolveScoped(ctx *wcontext.Context, p *provider) (any, error) {
+	c.scopeMu.Lock()
+	scope, ok := c.scopes[ctx]
+	if !ok {
+		scope = &requestScope{instances: make(map[reflect.Type]any), creating: make(map[reflect.Type]bool)}
+		c.scopes[ctx] = scope
+	}
+	if instance, ok := scope.instances[p.key]; ok {
+		c.scopeMu.Unlock()
+		return instance, nil
+	}
+	if scope.creating[p.key] {
+		c.scopeMu.Unlock()
+		return nil, fmt.Errorf("%w: %v", SERVICE_CYCLE, p.key)
+	}
+	scope.creating[p.key] = true
+	c.scopeMu.Unlock()
+
+	instance, err := p.factory(&Container{registry: c.registry}, ctx)
+
+	c.scopeMu.Lock()
+	defer c.scopeMu.Unlock()
+	delete(scope.creating, p.key)
+	if err != nil {
+		return nil, err
+	}
+	scope.instances[p.key] = instance
+	scope.order = append(scope.order, instance)
+	return instance, nil
+}
+
+// 请求结束，关闭请求级服务
+func (h *HttpServer) endRequest(ctx *wcontext.Context) {
+	if err := h.container.endRequest(ctx); err != nil {
+		ctx.Logger().Error("close request scoped services failed", "error", err)
+	}
+}
+
+func (c *Container) endRequest(ctx *wcontext.Context) error {
+	if !c.hasRequestScope.Load() {
+		return nil
+	}
+	c.scopeMu.Lock()
+	scope, ok := c.scopes[ctx]
+	delete(c.scopes, ctx)
+	c.scopeMu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	var errs []error
+	for i := len(scope.order) - 1; i >= 0; i-- {
+		errs = append(errs, closeService(scope.order[i]))
+	}
+	return errors.Join(errs...)
+}
+
+// 按创建的逆序关闭单例服务，重复调用无效
+func (c *Container) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	created := c.created
+	c.created = nil
+	c.mu.Unlock()
+
+	var errs []error
+	for i := len(created) - 1; i >= 0; i-- {
+		errs = append(errs, closeService(created[i].instance))
+	}
+	return errors.Join(errs...)
+}
+
+// 服务实现了Close() error或Close()时调用
+func closeService(instance any) error {
+	switch closer := instance.(type) {
+	case interface{ Close() error }:
+		return closer.Close()
+	case interface{ Close() }:
+		closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+type serviceA struct{}
+type serviceB struct{}
+
+// 关闭时记录名称
+type closer struct {
+	name   string
+	mu     *sync.Mutex
+	closed *[]string
+}
+
+func (c *closer) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	*c.closed = append(*c.closed, c.name)
+	return nil
+}
+
+var (
+	typeA = reflect.TypeOf(serviceA{})
+	typeB = reflect.TypeOf(serviceB{})
+)
+
+func TestConcurrentSingletonCycle(t *testing.T) {
+	c := NewContainer()
+
+	// 两个获取过程分别开始创建A和B之后，再去获取对方
+	aStarted, bStarted := make(chan struct{}), make(chan struct{})
+	var onceA, onceB sync.Once
+	c.Provide(typeA, Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		onceA.Do(func() { close(aStarted) })
+		<-bStarted
+		_, err := c.Resolve(nil, typeB)
+		return serviceA{}, err
+	})
+	c.Provide(typeB, Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		onceB.Do(func() { close(bStarted) })
+		<-aStarted
+		_, err := c.Resolve(nil, typeA)
+		return serviceB{}, err
+	})
+
+	errs := make(chan error, 2)
+	for _, key := range []reflect.Type{typeA, typeB} {
+		go func(key reflect.Type) {
+			_, err := c.Resolve(nil, key)
+			errs <- err
+		}(key)
+	}
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-errs:
+			if !errors.Is(err, SERVICE_CYCLE) {
+				t.Fatalf("err = %v, want SERVICE_CYCLE", err)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("concurrent resolution of a cycle deadlocked")
+		}
+	}
+}
+
+func TestResolveAfterClose(t *testing.T) {
+	c := NewContainer()
+	var mu sync.Mutex
+	var closed []string
+	c.Provide(typeA, Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		return &closer{name: "a", mu: &mu, closed: &closed}, nil
+	})
+	if _, err := c.Resolve(nil, typeA); err != nil {
+		t.Fatal(err)
+	}
+
+	// 创建期间容器被关闭，新建的服务直接关闭
+	creating, release := make(chan struct{}), make(chan struct{})
+	c.Provide(typeB, Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		close(creating)
+		<-release
+		return &closer{name: "b", mu: &mu, closed: &closed}, nil
+	})
+	result := make(chan error, 1)
+	go func() {
+		_, err := c.Resolve(nil, typeB)
+		result <- err
+	}()
+	<-creating
+
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+	close(release)
+	if err := <-result; !errors.Is(err, CONTAINER_CLOSED) {
+		t.Fatalf("err = %v, want CONTAINER_CLOSED", err)
+	}
+
+	// 关闭后不再创建单例
+	c.Provide(reflect.TypeOf(0), Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		t.Error("factory called after Close")
+		return 0, nil
+	})
+	if _, err := c.Resolve(nil, reflect.TypeOf(0)); !errors.Is(err, CONTAINER_CLOSED) {
+		t.Fatalf("err = %v, want CONTAINER_CLOSED", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !reflect.DeepEqual(closed, []string{"a", "b"}) {
+		t.Fatalf("closed = %v", closed)
+	}
+}
+
+func TestRequestScopeClosedPerRequest(t *testing.T) {
+	srv := NewHttpServer()
+	var mu sync.Mutex
+	var closed []string
+	created := 0
+
+	srv.Services().Provide(typeA, Singleton, func(c *Container, ctx *wcontext.Context) (any, error) {
+		return &closer{name: "singleton", mu: &mu, closed: &closed}, nil
+	})
+	srv.Services().Provide(typeB, RequestScope, func(c *Container, ctx *wcontext.Context) (any, error) {
+		created++
+		return &closer{name: "outer", mu: &mu, closed: &closed}, nil
+	})
+	// 依赖B，后创建，先关闭
+	srv.Services().Provide(reflect.TypeOf(""), RequestScope, func(c *Container, ctx *wcontext.Context) (any, error) {
+		if _, err := c.Resolve(ctx, typeB); err != nil {
+			return nil, err
+		}
+		return &closer{name: "inner", mu: &mu, closed: &closed}, nil
+	})
+
+	srv.GET("/", func(ctx *wcontext.Context) {
+		for _, key := range []reflect.Type{reflect.TypeOf(""), typeB, typeA} {
+			if _, err := ctx.Services().Resolve(ctx, key); err != nil {
+				ctx.Fail(http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+		ctx.TEXT("ok")
+	})
+
+	for i := 1; i <= 2; i++ {
+		recorder := httptest.NewRecorder()
+		srv.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("status = %d, body = %s", recorder.Code, recorder.Body)
+		}
+		// 每个请求重新创建，请求结束时逆序关闭，单例不受影响
+		mu.Lock()
+		got := append([]string(nil), closed...)
+		closed = closed[:0]
+		mu.Unlock()
+		if created != i || !reflect.DeepEqual(got, []string{"inner", "outer"}) {
+			t.Fatalf("request %d: created = %d, closed = %v", i, created, got)
+		}
+	}
+
+	if len(srv.Services().scopes) != 0 {
+		t.Fatalf("request scopes leaked: %d", len(srv.Services().scopes))
+	}
+	srv.Services().Close()
+	if !reflect.DeepEqual(closed, []string{"singleton"}) {
+		t.Fatalf("closed = %v", closed)
+	}
+}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"text/tabwriter"
+)
+
+// 设置后服务启动时只输出路由表，不执行启动钩子也不监听，启动返回DRY_RUN
+// 值为1或true时以表格输出到标准输出，其他值作为文件路径写入JSON（供weber routes命令读取）
+const ENV_DRY_RUN = "WEBER_DRY_RUN"
+
+var (
+	DRY_RUN = errors.New("dry run, server not started")
+)
+
+// 一个进程只输出一次路由表，多个服务启动时不会互相覆盖
+var (
+	dryRunOnce sync.Once
+	dryRunErr  error
+)
+
+// 路由表中的一项
+type RouteInfo struct {
+	// 虚拟主机的主机名，普通服务为空
+	Host        string   `json:"host,omitempty"`
+	Method      string   `json:"method"`
+	Pattern     string   `json:"pattern"`
+	Handler     string   `json:"handler"`
+	Middlewares []string `json:"middlewares,omitempty"`
+}
+
+// 按注册顺序返回所有路由
+func (h *HttpServer) RouteTable() []RouteInfo {
+	routes := h.routers.Routes()
+	table := make([]RouteInfo, 0, len(routes))
+	for _, route := range routes {
+		table = append(table, RouteInfo{
+			Method:      route.Method,
+			Pattern:     route.Pattern,
+			Handler:     funcName(route.Handler),
+			Middlewares: funcNames(route.Chain),
+		})
+	}
+	return table
+}
+
+// 处于dry-run模式时输出路由表并返回DRY_RUN
+// 路由表只由第一个启动的服务输出，管理端等内部服务不输出
+func (h *HttpServer) dryRun() error {
+	target, ok := os.LookupEnv(ENV_DRY_RUN)
+	if !ok || target == "" || target == "0" || target == "false" {
+		return nil
+	}
+	if h.internal {
+		return DRY_RUN
+	}
+
+	dryRunOnce.Do(func() {
+		table := h.RouteTable()
+		if h.routeTable != nil {
+			table = h.routeTable()
+		}
+		dryRunErr = writeDryRun(target, table)
+	})
+	if dryRunErr != nil {
+		return dryRunErr
+	}
+	return DRY_RUN
+}
+
+func writeDryRun(target string, table []RouteInfo) error {
+	if target == "1" || target == "true" {
+		return WriteRouteTable(os.Stdout, table)
+	}
+
+	data, err := json.MarshalIndent(table, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(target, data, 0o644)
+}
+
+// 以表格输出路由表，包含虚拟主机时增加HOST列
+func WriteRouteTable(w io.Writer, table []RouteInfo) error {
+	withHost := false
+	for _, route := range table {
+		withHost = withHost || route.Host != ""
+	}
+
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	if withHost {
+		fmt.Fprintln(writer, "HOST\tMETHOD\tPATTERN\tHANDLER")
+	} else {
+		fmt.Fprintln(writer, "METHOD\tPATTERN\tHANDLER")
+	}
+	for _, route := range table {
+		if withHost {
+			fmt.Fprintf(writer, "%s\t", route.Host)
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", route.Method, route.Pattern, route.Handler)
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,11 @@
+package server
+
+import "errors"
+
+var (
+	UPGRADE_UNSUPPORTED   = errors.New("listener inheritance isn't supported on this platform!")
+	UPGRADE_NOT_READY     = errors.New("upgraded process didn't become ready in time!")
+	REUSEPORT_UNSUPPORTED = errors.New("SO_REUSEPORT isn't supported on this platform!")
+	REGISTER_AFTER_START  = errors.New("routes and middlewares can't be registered after the server started!")
+	UNIX_PATH_NOT_SOCKET  = errors.New("unix socket path exists and isn't a socket!")
+)
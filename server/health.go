@@ -0,0 +1,230 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 健康检查，返回nil表示健康
+type HealthCheck func(ctx context.Context) error
+
+const (
+	// 单个检查的默认超时时间
+	DefaultCheckTimeout = 3 * time.Second
+)
+
+var (
+	CHECK_TIMEOUT = errors.New("health check timed out")
+	NOT_READY     = errors.New("server is not ready")
+)
+
+type healthCheck struct {
+	name  string
+	check HealthCheck
+
+	timeout time.Duration
+
+	// 结果的缓存时间，为0时每次都执行
+	cacheTTL time.Duration
+
+	// 是否同时作为存活检查
+	liveness bool
+
+	// 同一时间只执行一次，其余请求等待并复用结果
+	mu        sync.Mutex
+	lastErr   error
+	lastCheck time.Time
+	lastTook  time.Duration
+}
+
+type CheckOption func(check *healthCheck)
+
+// 单次检查的超时时间，默认DefaultCheckTimeout
+func WithCheckTimeout(timeout time.Duration) CheckOption {
+	return func(check *healthCheck) {
+		check.timeout = timeout
+	}
+}
+
+// 缓存检查结果，ttl内的探测直接返回上一次的结果，避免探测压垮依赖
+func WithCheckCache(ttl time.Duration) CheckOption {
+	return func(check *healthCheck) {
+		check.cacheTTL = ttl
+	}
+}
+
+// 同时作为存活检查（/livez），默认只参与就绪检查（/readyz）
+// 存活检查失败会导致容器被重启，只用于进程自身无法恢复的故障
+func WithLiveness() CheckOption {
+	return func(check *healthCheck) {
+		check.liveness = true
+	}
+}
+
+// 注册健康检查，同名检查会被替换
+//
+//	srv.AddCheck("db", func(ctx context.Context) error {
+//		return db.PingContext(ctx)
+//	}, server.WithCheckTimeout(time.Second), server.WithCheckCache(5*time.Second))
+func (h *HttpServer) AddCheck(name string, check HealthCheck, options ...CheckOption) {
+	hc := &healthCheck{name: name, check: check, timeout: DefaultCheckTimeout}
+	for _, option := range options {
+		option(hc)
+	}
+
+	h.healthMu.Lock()
+	defer h.healthMu.Unlock()
+	for idx, existing := range h.checks {
+		if existing.name == name {
+			h.checks[idx] = hc
+			return
+		}
+	}
+	h.checks = append(h.checks, hc)
+}
+
+// 注册/livez和/readyz，prefix为空时挂载在根路由上
+// 默认返回纯文本ok，带verbose参数时返回每项检查的JSON，exclude参数可以跳过指定的检查
+// 开始优雅关闭后/readyz立即返回503，负载均衡据此摘除流量
+//
+//	GET /readyz?verbose&exclude=cache
+func (h *HttpServer) EnableHealth(prefix string) {
+	group := h.RouterGroup
+	if prefix != "" {
+		group = h.Group(prefix)
+	}
+	group.GET("/livez", func(ctx *wcontext.Context) {
+		h.serveHealth(ctx, true)
+	})
+	group.GET("/readyz", func(ctx *wcontext.Context) {
+		h.serveHealth(ctx, false)
+	})
+}
+
+type checkResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+	Cached   bool   `json:"cached,omitempty"`
+}
+
+func (h *HttpServer) serveHealth(ctx *wcontext.Context, liveness bool) {
+	exclude := make(map[string]bool)
+	if names, err := ctx.GetQuery("exclude"); err == nil {
+		for _, name := range names {
+			exclude[name] = true
+		}
+	}
+
+	h.healthMu.Lock()
+	checks := make([]*healthCheck, 0, len(h.checks))
+	for _, check := range h.checks {
+		if (!liveness || check.liveness) && !exclude[check.name] {
+			checks = append(checks, check)
+		}
+	}
+	h.healthMu.Unlock()
+
+	results := make([]checkResult, len(checks))
+	var wg sync.WaitGroup
+	for idx, check := range checks {
+		wg.Add(1)
+		go func(idx int, check *healthCheck) {
+			defer wg.Done()
+			results[idx] = check.run(ctx.Request().Context())
+		}(idx, check)
+	}
+	wg.Wait()
+
+	// 就绪状态由服务生命周期决定，开始关闭后不再接收流量
+	if !liveness {
+		ready := checkResult{Name: "server", Status: "ok", Duration: "0s"}
+		if !h.Ready() {
+			ready.Status = "failed"
+			ready.Error = NOT_READY.Error()
+		}
+		results = append([]checkResult{ready}, results...)
+	}
+
+	healthy := true
+	for _, result := range results {
+		if result.Status != "ok" {
+			healthy = false
+		}
+	}
+
+	status := http.StatusOK
+	text := "ok"
+	if !healthy {
+		status = http.StatusServiceUnavailable
+		text = "unhealthy"
+	}
+
+	if _, err := ctx.GetQuery("verbose"); err == nil {
+		ctx.JSON(wcontext.H{"status": text, "checks": results})
+	} else {
+		ctx.TEXT(text)
+	}
+	ctx.SetStatusCode(status)
+}
+
+// 执行检查，缓存未过期时直接返回上一次的结果
+func (c *healthCheck) run(parent context.Context) checkResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cached := c.cacheTTL > 0 && !c.lastCheck.IsZero() && time.Since(c.lastCheck) < c.cacheTTL
+	if !cached {
+		start := time.Now()
+		c.lastErr = c.execute(parent)
+		c.lastTook = time.Since(start)
+		c.lastCheck = time.Time{}
+		// 探测请求被取消时的结果不缓存
+		if parent.Err() == nil {
+			c.lastCheck = time.Now()
+		}
+	}
+
+	result := checkResult{Name: c.name, Status: "ok", Duration: c.lastTook.String(), Cached: cached}
+	if c.lastErr != nil {
+		result.Status = "failed"
+		result.Error = c.lastErr.Error()
+	}
+	return result
+}
+
+// 在超时时间内执行检查，检查函数忽略ctx时也能按时返回
+func (c *healthCheck) execute(parent context.Context) (err error) {
+	ctx := parent
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(parent, c.timeout)
+		defer cancel()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				done <- errors.New("health check panicked")
+			}
+		}()
+		done <- c.check(ctx)
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return CHECK_TIMEOUT
+		}
+		return ctx.Err()
+	}
+}
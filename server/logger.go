@@ -0,0 +1,40 @@
+package server
+
+import (
+	"log"
+	"log/slog"
+	"os"
+)
+
+// 默认的框架日志：JSON格式，每条一行，输出到标准错误，级别由WithLogLevel调整
+func defaultLogger(level *slog.LevelVar) *slog.Logger {
+	return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: level}))
+}
+
+// 使用自定义的日志，框架日志和ctx.Logger()都通过它输出
+// 日志级别由logger自身的Handler决定，WithLogLevel不再生效
+func WithLogger(logger *slog.Logger) HttpOption {
+	return func(h *HttpServer) {
+		h.logger = logger
+	}
+}
+
+// 默认日志的级别，默认为Info
+func WithLogLevel(level slog.Level) HttpOption {
+	return func(h *HttpServer) {
+		h.logLevel.Set(level)
+	}
+}
+
+// 框架日志，可用于请求之外（启动钩子、模块等）
+func (h *HttpServer) Logger() *slog.Logger {
+	return h.logger
+}
+
+// net/http内部错误（例如TLS握手失败）的日志，未通过WithErrorLog设置时输出到框架日志
+func (h *HttpServer) errorLog() *log.Logger {
+	if h.tuning.errorLog != nil {
+		return h.tuning.errorLog
+	}
+	return slog.NewLogLogger(h.logger.Handler(), slog.LevelError)
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 模块：把一组路由、中间件和生命周期打包，由服务统一安装
+//
+//	type Billing struct{}
+//
+//	func (Billing) Name() string                { return "billing" }
+//	func (Billing) Dependencies() []string      { return []string{"auth"} }
+//	func (Billing) Register(g *server.RouterGroup) {
+//		g.Use(verifySignature)
+//		g.POST("/webhook", handleWebhook)
+//	}
+//
+//	srv.Install(auth.Module(), server.Mount("/billing", Billing{}))
+type Module interface {
+	// 模块名称，用于声明依赖，同一服务中不能重复
+	Name() string
+
+	// 在模块自己的路由组上注册路由和中间件，中间件只作用于本模块
+	Register(group *RouterGroup)
+}
+
+// 可选：声明依赖的模块名称，依赖的模块先注册、先启动、后关闭
+type ModuleDependencies interface {
+	Dependencies() []string
+}
+
+// 可选：模块的路由前缀，未实现时挂载在根路由上
+type ModulePrefix interface {
+	Prefix() string
+}
+
+// 可选：服务启动（开始监听）前执行
+type ModuleStarter interface {
+	Start() error
+}
+
+// 可选：服务关闭、请求排空后执行
+type ModuleStopper interface {
+	Stop(ctx context.Context) error
+}
+
+var (
+	MODULE_DUPLICATE  = errors.New("module already installed")
+	MODULE_MISSING    = errors.New("module dependency not installed")
+	MODULE_CYCLE      = errors.New("module dependency cycle")
+	MODULE_START_FAIL = errors.New("module start failed")
+)
+
+// 为模块指定路由前缀，覆盖模块自身的Prefix
+func Mount(prefix string, module Module) Module {
+	return &mountedModule{Module: module, prefix: prefix}
+}
+
+type mountedModule struct {
+	Module
+	prefix string
+}
+
+func (m *mountedModule) Prefix() string {
+	return m.prefix
+}
+
+func (m *mountedModule) Dependencies() []string {
+	return moduleDependencies(m.Module)
+}
+
+func (m *mountedModule) Start() error {
+	if starter, ok := m.Module.(ModuleStarter); ok {
+		return starter.Start()
+	}
+	return nil
+}
+
+func (m *mountedModule) Stop(ctx context.Context) error {
+	if stopper, ok := m.Module.(ModuleStopper); ok {
+		return stopper.Stop(ctx)
+	}
+	return nil
+}
+
+func moduleDependencies(module Module) []string {
+	if deps, ok := module.(ModuleDependencies); ok {
+		return deps.Dependencies()
+	}
+	return nil
+}
+
+// 安装模块：按依赖排序后依次注册路由，服务启动时按同样的顺序启动，关闭时逆序关闭
+// 依赖可以是之前已安装的模块，也可以是本次一起安装的模块
+func (h *HttpServer) Install(modules ...Module) error {
+	installed := make(map[string]bool, len(h.modules))
+	for _, module := range h.modules {
+		installed[module.Name()] = true
+	}
+
+	pending := make(map[string]Module, len(modules))
+	for _, module := range modules {
+		name := module.Name()
+		if installed[name] || pending[name] != nil {
+			return fmt.Errorf("%w: %s", MODULE_DUPLICATE, name)
+		}
+		pending[name] = module
+	}
+
+	// 深度优先的拓扑排序，保持传入的顺序
+	ordered := make([]Module, 0, len(modules))
+	visiting := make(map[string]bool)
+	var visit func(module Module, path []string) error
+	visit = func(module Module, path []string) error {
+		name := module.Name()
+		if installed[name] {
+			return nil
+		}
+		path = append(path, name)
+		if visiting[name] {
+			return fmt.Errorf("%w: %s", MODULE_CYCLE, strings.Join(path, " -> "))
+		}
+		visiting[name] = true
+		for _, dep := range moduleDependencies(module) {
+			if installed[dep] {
+				continue
+			}
+			depModule, ok := pending[dep]
+			if !ok {
+				return fmt.Errorf("%w: %s requires %s", MODULE_MISSING, name, dep)
+			}
+			if err := visit(depModule, path); err != nil {
+				return err
+			}
+		}
+		visiting[name] = false
+		installed[name] = true
+		ordered = append(ordered, module)
+		return nil
+	}
+	for _, module := range modules {
+		if err := visit(module, nil); err != nil {
+			return err
+		}
+	}
+
+	if len(h.modules) == 0 && len(ordered) > 0 {
+		h.OnStart(h.startModules)
+		h.OnShutdown(h.stopModules)
+	}
+	for _, module := range ordered {
+		module.Register(h.moduleGroup(module))
+		h.modules = append(h.modules, module)
+	}
+	return nil
+}
+
+// 模块自己的路由组，没有前缀时与根路由同前缀，但中间件互不影响
+func (h *HttpServer) moduleGroup(module Module) *RouterGroup {
+	prefix := ""
+	if p, ok := module.(ModulePrefix); ok {
+		prefix = strings.Trim(p.Prefix(), "/")
+	}
+	if prefix != "" {
+		return h.Group(prefix)
+	}
+	group := &RouterGroup{prefix: h.RouterGroup.prefix, parent: h.RouterGroup, engine: h.RouterGroup.engine}
+	h.addGroup(group)
+	return group
+}
+
+// 按安装顺序启动模块，失败时关闭已启动的模块
+func (h *HttpServer) startModules() error {
+	for idx, module := range h.modules {
+		starter, ok := module.(ModuleStarter)
+		if !ok {
+			continue
+		}
+		if err := starter.Start(); err != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+			defer cancel()
+			return errors.Join(fmt.Errorf("%w: %s: %w", MODULE_START_FAIL, module.Name(), err), stopModules(ctx, h.modules[:idx]))
+		}
+	}
+	return nil
+}
+
+func (h *HttpServer) stopModules(ctx context.Context) error {
+	return stopModules(ctx, h.modules)
+}
+
+// 逆序关闭模块
+func stopModules(ctx context.Context, modules []Module) error {
+	var errs []error
+	for i := len(modules) - 1; i >= 0; i-- {
+		if stopper, ok := modules[i].(ModuleStopper); ok {
+			if err := stopper.Stop(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", modules[i].Name(), err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,6 @@
+//go:build linux && !mips && !mipsle && !mips64 && !mips64le
+
+package server
+
+// syscall包在部分架构（如amd64）下没有导出SO_REUSEPORT，除mips外取值都相同
+const soReusePort = 0xf
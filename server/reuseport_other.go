@@ -0,0 +1,9 @@
+//go:build !linux && !darwin && !dragonfly && !freebsd && !netbsd && !openbsd
+
+package server
+
+import "syscall"
+
+func reusePortControl(network, address string, conn syscall.RawConn) error {
+	return REUSEPORT_UNSUPPORTED
+}
@@ -2,13 +2,13 @@ package server
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"log"
+	"log/slog"
+	"net"
 	"net/http"
-	"os"
-	"os/signal"
-	"strings"
-	"syscall"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/asxlwsl/weber/middleware"
@@ -25,20 +25,55 @@ type Server interface {
 	//http.ListenAndServe需要一个Handler对象参数
 	http.Handler
 
-	//启动服务
+	//启动服务（非阻塞，监听成功后立即返回）
 	Start(addr string) error
 
+	//在给定的监听上提供服务（非阻塞）
+	Serve(listener net.Listener) error
+
 	//关闭服务
 	Stop() error
 
+	//优雅关闭服务，ctx控制排空请求的最长时间
+	Shutdown(ctx context.Context) error
+
+	//阻塞直到服务退出
+	Wait() error
+
+	//热升级，把监听交给新进程
+	Upgrade() error
+
 	//核心
-	addRouter(method string, pattern string, handlwFunc wcontext.HandleFunc, handleChains ...MiddlewareHandleFunc)
+	addRouter(group *RouterGroup, method string, pattern string, handlwFunc wcontext.HandleFunc, handleChains ...MiddlewareHandleFunc)
 
 	addGroup(group *RouterGroup)
+
+	// 启动后注册路由、中间件和路由组时panic
+	mustNotStarted()
+
+	// 中间件变化后重新编译所有路由的处理链
+	compileRoutes()
+
+	// 注册启动钩子
+	OnStart(hooks ...StartHook)
+
+	// 注册关闭钩子
+	OnShutdown(hooks ...ShutdownHook)
+
+	// 框架日志
+	Logger() *slog.Logger
 }
 
 type HttpOption func(h *HttpServer)
 
+// 关闭时执行的钩子函数
+type ShutdownHook func(ctx context.Context) error
+
+const (
+	// 默认的排空超时时间
+	DefaultShutdownTimeout = 5 * time.Second
+)
+
 type HttpServer struct {
 	serv *http.Server
 
@@ -52,41 +87,68 @@ type HttpServer struct {
 	*RouterGroup
 
 	groups []*RouterGroup
-}
 
-// 默认的关闭方案
-func defaultHttpStop(h *HttpServer) func() error {
-	return func() error {
-		fmt.Println("== execute default close ==")
+	// 路由所属的路由组，用于查找路由组中间件
+	routeGroups map[*router.Route]*RouterGroup
 
-		quitSig := make(chan os.Signal)
+	// 全局中间件，分别位于Flush之前和之后
+	beforeFlush []MiddlewareHandleFunc
+	globals     []MiddlewareHandleFunc
 
-		//接收到用户终止信号（例如Ctrl+C）,将收到的信号传入quitSig的chan中
-		//此处会阻塞，直到接收到信号
-		signal.Notify(quitSig, syscall.SIGINT, syscall.SIGTERM)
-		<-quitSig
+	// 错误恢复中间件，位于Flush之后，可替换
+	recovery MiddlewareHandleFunc
 
-		fmt.Println("== shutdown ==")
+	// 通过配置文件启用的中间件，供管理接口查看
+	configMiddlewares []MiddlewareConfig
 
-		//超时5秒钟的上下文
-		ctx, cancel := context.WithTimeout(context.Background(), 1*time.Second)
+	// 排空请求的超时时间
+	shutdownTimeout time.Duration
 
-		defer cancel()
+	// 就绪状态置为false后，等待负载均衡摘除流量的时间
+	drainDelay time.Duration
 
-		//关闭之前进行的操作
+	// 是否就绪（可以接收流量）
+	ready atomic.Bool
 
-		//执行http内置的关闭方法，传入ctx上下文
-		if err := h.serv.Shutdown(ctx); err != nil {
-			log.Fatal("Server shutdown error,", err)
-		}
+	// 是否已开始关闭，之后启动的监听不再标记为就绪
+	shuttingDown atomic.Bool
 
-		//关闭之后进行的操作
+	// 关闭时按注册顺序执行的钩子
+	shutdownHooks []ShutdownHook
 
-		select {
-		case <-ctx.Done():
-			log.Println("timeout of 5 seconds")
-		}
-		return nil
+	// 健康检查，见health.go
+	healthMu sync.Mutex
+	checks   []*healthCheck
+
+	// 服务容器，见container.go
+	container *Container
+
+	// 已安装的模块，按依赖排序，见module.go
+	modules []Module
+
+	// 框架日志，见logger.go
+	logger   *slog.Logger
+	logLevel slog.LevelVar
+
+	// 生命周期钩子
+	lifecycle
+
+	// http.Server的超时等参数，见server_options.go
+	tuning serverTuning
+
+	// 监听相关，见server_listener.go
+	listenerState
+
+	shutdownOnce sync.Once
+	shutdownErr  error
+}
+
+// 默认的关闭方案
+func defaultHttpStop(h *HttpServer) func() error {
+	return func() error {
+		ctx, cancel := context.WithTimeout(context.Background(), h.shutdownTimeout)
+		defer cancel()
+		return h.Shutdown(ctx)
 	}
 }
 
@@ -99,6 +161,20 @@ func WithHttpServerStop(fn func() error) HttpOption {
 	}
 }
 
+// 设置排空请求的超时时间
+func WithShutdownTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.shutdownTimeout = timeout
+	}
+}
+
+// 设置就绪状态切换后到开始排空之间的等待时间
+func WithDrainDelay(delay time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.drainDelay = delay
+	}
+}
+
 // 构造方法
 func NewHttpServer(options ...HttpOption) *HttpServer {
 
@@ -109,6 +185,17 @@ func NewHttpServer(options ...HttpOption) *HttpServer {
 		routers: router.NewRouter(),
 
 		RouterGroup: rootGroup,
+
+		routeGroups: make(map[*router.Route]*RouterGroup),
+
+		recovery: middleware.Recovery(),
+
+		shutdownTimeout: DefaultShutdownTimeout,
+
+		tuning: defaultServerTuning(),
+
+		// 在构造时创建，请求中无锁读取
+		container: NewContainer(),
 	}
 	rootGroup.engine = &server
 	hServer, ok := server.(*HttpServer)
@@ -117,29 +204,38 @@ func NewHttpServer(options ...HttpOption) *HttpServer {
 		log.Panicln("生成Server失败")
 		return nil
 	}
+	hServer.stop = defaultHttpStop(hServer)
+	hServer.upgradeTimeout = DefaultUpgradeTimeout
+	hServer.logger = defaultLogger(&hServer.logLevel)
 
 	for _, option := range options {
 		option(hServer)
 	}
+	hServer.compileRoutes()
 	return hServer
 }
 
-// 匹配中间件(对应当前URL)
-// 中间件在各个路由组上
-// 需要在HttpServer上维护整个项目有的路由组
-func (h *HttpServer) filterMiddlewares(pattern string) []MiddlewareHandleFunc {
+// 匹配中间件
+// 从路由所属的路由组沿parent向上查找到根路由组，外层路由组的中间件先执行
+// 不按URL前缀匹配，/v1的中间件不会作用于/v10下的路由
+func (h *HttpServer) groupMiddlewares(group *RouterGroup) []MiddlewareHandleFunc {
+
+	groups := make([]*RouterGroup, 0)
+	for ; group != nil; group = group.parent {
+		groups = append(groups, group)
+	}
 
 	middlewares := make([]MiddlewareHandleFunc, 0)
-	for _, group := range h.groups {
-		if strings.HasPrefix(pattern, group.prefix) {
-			middlewares = append(middlewares, group.middlewares...)
-		}
+	for i := len(groups) - 1; i >= 0; i-- {
+		middlewares = append(middlewares, groups[i].middlewares...)
 	}
 	return middlewares
 }
 
 func (h *HttpServer) addGroup(group *RouterGroup) {
+	h.mustNotStarted()
 	h.groups = append(h.groups, group)
+	h.runGroup(group.prefix)
 }
 
 // 接收客户端请求，转发请求到框架，由框架进行处理
@@ -162,81 +258,210 @@ func (h *HttpServer) ServeHTTP(writer http.ResponseWriter, request *http.Request
 			writer.Write([]byte("404 not found!"))
 		}
 	*/
-	// 生成上下文
-	ctx := wcontext.NewContext(writer, request)
+	// 生成上下文（从对象池获取，处理完毕后回收）
+	ctx := wcontext.AcquireContext(writer, request)
+	defer wcontext.ReleaseContext(ctx)
+	ctx.SetLogger(h.logger)
+
+	ctx.SetServices(h.container)
+	defer h.endRequest(ctx)
 
-	// 获取全局中间件
-	middlewares := []MiddlewareHandleFunc{middleware.Flush(), middleware.Recovery()}
+	// 路由匹配，得到注册时已经编译好的完整处理链
+	// 未匹配时返回同样包含全局中间件的404/405处理链
+	handleFunc := h.routers.GetRouter(ctx)
 
-	// 获取路由中间价
-	rmids := h.filterMiddlewares(ctx.Pattern)
+	handleFunc(ctx)
+}
 
-	if len(rmids) != 0 {
-		middlewares = append(middlewares, rmids...)
+// 全局中间件，作用于所有请求（包括未匹配到路由的请求）
+// 顺序：UseBeforeFlush注册的中间件 -> Flush -> Recovery -> Use注册的中间件
+func (h *HttpServer) globalMiddlewares() []MiddlewareHandleFunc {
+	middlewares := append([]MiddlewareHandleFunc{}, h.beforeFlush...)
+	middlewares = append(middlewares, middleware.Flush())
+	if h.recovery != nil {
+		middlewares = append(middlewares, h.recovery)
 	}
-	/*
-		// 无论有没有中间件，都将视图函数构建成中间件
-		// 将形式统一，以中间件的形式执行
-		// 当前请求没有中间件
-		if len(middlewares) == 0 {
-			middlewares = make([]MiddlewareHandleFunc, 0)
-		}
-	*/
+	return append(middlewares, h.globals...)
+}
 
-	// 路由匹配
-	handler := h.routers.GetRouter(ctx)
+// 注册全局中间件，作用于所有请求（包括404/405）
+// 位于Flush之后，返回时响应尚未写出，可以修改状态码、响应头和响应体
+// 与路由一样只能在启动前注册，启动后调用会panic（REGISTER_AFTER_START）
+func (h *HttpServer) Use(middlewares ...MiddlewareHandleFunc) {
+	// 先检查再修改，避免启动后修改正在使用的中间件
+	h.mustNotStarted()
+	h.globals = append(h.globals, middlewares...)
+	h.compileRoutes()
+}
 
-	/* 内部处理完成，必定存在handler，包括出错的handler
-	if handler == nil {
-		handler = wcontext.HandleNotFound
-	}
-	*/
+// 注册位于Flush之前的全局中间件
+// next返回时响应已经写出，适合统计耗时、记录最终结果等不修改响应的逻辑
+// 启动后调用会panic（REGISTER_AFTER_START）
+func (h *HttpServer) UseBeforeFlush(middlewares ...MiddlewareHandleFunc) {
+	h.mustNotStarted()
+	h.beforeFlush = append(h.beforeFlush, middlewares...)
+	h.compileRoutes()
+}
 
-	// 将匹配到的视图函数添加到mids
-	handleFunc := handler
+// 替换默认的错误恢复中间件（例如上报panic），传入nil则不使用错误恢复
+func WithRecovery(recovery MiddlewareHandleFunc) HttpOption {
+	return func(h *HttpServer) {
+		h.recovery = recovery
+	}
+}
 
-	// 构造责任链(从内向外)
-	/*
-		- M1
-			-M2
-				-View
-			-M2
-		- M1
-	*/
+// 不使用错误恢复中间件，panic交给net/http处理
+func WithoutRecovery() HttpOption {
+	return WithRecovery(nil)
+}
 
+// 构造责任链(从内向外)
+/*
+	- M1
+		-M2
+			-View
+		-M2
+	- M1
+*/
+func buildChain(middlewares []MiddlewareHandleFunc, handler HandleFunc) HandleFunc {
 	for i := len(middlewares) - 1; i >= 0; i-- {
-		handleFunc = middlewares[i](handleFunc)
+		handler = middlewares[i](handler)
 	}
+	return handler
+}
 
-	handleFunc(ctx)
+// 编译路由的完整处理链：全局中间件 -> 路由组中间件（由外到内） -> 路由中间件 -> 视图函数
+func (h *HttpServer) compileRoute(route *router.Route) {
+	// 路由器内置的路由（默认首页）属于根路由组
+	group, ok := h.routeGroups[route]
+	if !ok {
+		group = h.RouterGroup
+	}
+	middlewares := append(h.globalMiddlewares(), h.groupMiddlewares(group)...)
+	middlewares = append(middlewares, route.Chain...)
+	route.SetHandle(buildChain(middlewares, route.Handler))
+}
 
-	/*
-		// 调用对应路由处理
-		if handler != nil {
-			handler(ctx)
-		} else if !ctx.Done {
-			wcontext.HandleNotFound(ctx)
-		}
-	*/
+// 重新编译所有路由，中间件变化后调用
+func (h *HttpServer) compileRoutes() {
+	h.mustNotStarted()
+	for _, route := range h.routers.Routes() {
+		h.compileRoute(route)
+	}
 
-	//处理完毕，写入数据
-	// ctx.Complete()
+	// 未匹配到路由时只经过全局中间件和根路由组的中间件
+	fallback := append(h.globalMiddlewares(), h.RouterGroup.middlewares...)
+	h.routers.SetNotFound(buildChain(fallback, wcontext.HandleNotFound()))
+	h.routers.SetMethodNotAllowed(buildChain(fallback, wcontext.HandleMethodNotAllowed()))
+}
+
+// 启动完成后不能再注册路由和中间件，否则会与正在处理的请求产生数据竞争
+func (h *HttpServer) mustNotStarted() {
+	if h.sealed.Load() {
+		panic(REGISTER_AFTER_START)
+	}
 }
 
 func (h *HttpServer) Start(addr string) error {
 	// return http.ListenAndServe(addr, h)
-	httpServer := &http.Server{
-		Addr:    addr,
-		Handler: h,
+	if err := h.prepare(); err != nil {
+		return err
+	}
+	listener, err := h.listen("tcp", addr)
+	if err != nil {
+		return err
 	}
-	h.serv = httpServer
-	return httpServer.ListenAndServe()
+	return h.serve(listener)
 }
 
 func (h *HttpServer) Stop() error {
 	return h.stop()
 }
 
+// 是否可以接收流量，开始关闭后立即变为false
+func (h *HttpServer) Ready() bool {
+	return h.ready.Load()
+}
+
+// 注册关闭钩子，在请求排空后按注册顺序执行（例如刷新日志、关闭数据库连接）
+func (h *HttpServer) OnShutdown(hooks ...ShutdownHook) {
+	h.shutdownHooks = append(h.shutdownHooks, hooks...)
+}
+
+// 优雅关闭
+//  1. 就绪状态置为false，执行OnStop钩子，等待drainDelay让负载均衡摘除流量
+//  2. 停止监听并等待正在处理的请求完成，超过ctx时限则强制关闭连接
+//  3. 按注册顺序执行关闭钩子，最后关闭服务容器
+//
+// 多次调用只会执行一次
+func (h *HttpServer) Shutdown(ctx context.Context) error {
+	h.shutdownOnce.Do(func() {
+		h.shutdownErr = h.shutdown(ctx)
+	})
+	return h.shutdownErr
+}
+
+func (h *HttpServer) shutdown(ctx context.Context) error {
+	errs := h.beginShutdown(ctx)
+
+	if h.drainDelay > 0 {
+		timer := time.NewTimer(h.drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	// 所有监听共用一个http.Server，一次Shutdown即可全部关闭
+	if serv := h.currentServer(); serv != nil {
+		if err := serv.Shutdown(ctx); err != nil {
+			// 超时仍未排空，强制关闭剩余连接
+			errs = append(errs, err, serv.Close())
+		}
+	}
+
+	errs = append(errs, h.finishShutdown(ctx)...)
+	if err := errors.Join(errs...); err != nil {
+		h.logger.Warn("server stopped with errors", "error", err)
+		return err
+	}
+	h.logger.Info("server stopped")
+	return nil
+}
+
+// 标记为就绪，已开始关闭时无效
+func (h *HttpServer) markReady() {
+	h.ready.Store(true)
+	// 与beginShutdown并发时，先置为就绪再检查，保证最终不会处于就绪状态
+	if h.shuttingDown.Load() {
+		h.ready.Store(false)
+	}
+}
+
+// 关闭的第一阶段：不再就绪，执行OnStop钩子
+func (h *HttpServer) beginShutdown(ctx context.Context) []error {
+	h.shuttingDown.Store(true)
+	h.ready.Store(false)
+	h.logger.Info("server shutting down")
+	return h.runStop(ctx)
+}
+
+// 关闭的最后阶段：请求排空后执行OnShutdown钩子，再关闭服务容器
+func (h *HttpServer) finishShutdown(ctx context.Context) []error {
+	var errs []error
+	for _, hook := range h.shutdownHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	// 钩子中可能还会使用容器中的服务，最后关闭容器
+	if err := h.container.Close(); err != nil {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
 // 注册路由
 // 注册路由的时机
 //
@@ -245,7 +470,7 @@ func (h *HttpServer) Stop() error {
 // 注册的路由如何存储
 //
 //	方案一：map[method-pattern]HandleFunc
-func (h *HttpServer) addRouter(method string, pattern string, hangleFunc wcontext.HandleFunc, handleChain ...MiddlewareHandleFunc) {
+func (h *HttpServer) addRouter(group *RouterGroup, method string, pattern string, hangleFunc wcontext.HandleFunc, handleChain ...MiddlewareHandleFunc) {
 	/*
 		key := fmt.Sprintf("%s-%s", method, pattern)
 
@@ -254,7 +479,14 @@ func (h *HttpServer) addRouter(method string, pattern string, hangleFunc wcontex
 		h.routers[key] = hangleFunc
 	*/
 
-	h.routers.AddRouter(method, pattern, hangleFunc, handleChain...)
+	h.mustNotStarted()
+	route := h.routers.AddRouter(method, pattern, hangleFunc, handleChain...)
+	h.routeGroups[route] = group
+
+	// 注册时编译好完整的处理链，请求时不再组装
+	h.compileRoute(route)
+	h.logger.Debug("route registered", "method", method, "pattern", pattern)
+	h.runRoute(method, pattern)
 }
 
 /*
@@ -1,12 +1,21 @@
 package server
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/asxlwsl/weber/middleware"
+	"github.com/asxlwsl/weber/proxy"
 	"github.com/asxlwsl/weber/wcontext"
+	"github.com/asxlwsl/weber/websocket"
 )
 
 type MiddlewareHandleFunc = middleware.MiddlewareHandleFunc
@@ -41,7 +50,7 @@ type HandleFunc = wcontext.HandleFunc
 // 统一注册
 func (r *RouterGroup) addRouter(method string, pattern string, handler HandleFunc, handleChains ...MiddlewareHandleFunc) {
 	pattern = fmt.Sprintf("%s%s", r.prefix, pattern)
-	(*r.engine).addRouter(method, pattern, handler, handleChains...)
+	(*r.engine).addRouter(r, method, pattern, handler, handleChains...)
 }
 
 func (r *RouterGroup) GET(pattern string, handleFunc HandleFunc, handleChains ...MiddlewareHandleFunc) {
@@ -60,6 +69,50 @@ func (r *RouterGroup) PUT(pattern string, handleFunc HandleFunc, handleChains ..
 	r.addRouter(http.MethodPut, pattern, handleFunc, handleChains...)
 }
 
+// 静态资源：将prefix下的请求映射到dir目录中的文件
+func (r *RouterGroup) Static(prefix string, dir string) {
+	prefix = fmt.Sprintf("/%s", strings.Trim(prefix, "/"))
+	if prefix == "/" {
+		prefix = ""
+	}
+	handler := wcontext.HandleStaticFile(dir)
+	if prefix != "" {
+		// 访问目录本身时返回index.html
+		r.GET(prefix, handler)
+	}
+	r.GET(fmt.Sprintf("%s/*%s", prefix, wcontext.STATIC_PARAM), handler)
+}
+
+// 反向代理：将prefix下所有请求方法的请求转发到upstreams
+// 返回的Proxy可以继续设置负载均衡、健康检查、重试等，服务启动时开始健康检查，关闭时停止
+//
+//	g.Proxy("/api", "http://10.0.0.1:8080", "http://10.0.0.2:8080").
+//		Balance(proxy.LeastConn()).
+//		HealthCheck("/healthz", 5*time.Second, time.Second)
+func (r *RouterGroup) Proxy(prefix string, upstreams ...string) *proxy.Proxy {
+	prefix = fmt.Sprintf("/%s", strings.Trim(prefix, "/"))
+
+	p, err := proxy.New(r.prefix+prefix, upstreams...)
+	if err != nil {
+		log.Panicln("{ ", prefix, " } proxy register failed,", err)
+	}
+
+	methods := []string{
+		http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions,
+	}
+	for _, method := range methods {
+		r.addRouter(method, prefix, p.Handle)
+		r.addRouter(method, fmt.Sprintf("%s/*%s", prefix, proxy.PROXY_PARAM), p.Handle)
+	}
+
+	(*r.engine).OnStart(p.Start)
+	(*r.engine).OnShutdown(func(ctx context.Context) error {
+		return p.Close()
+	})
+	return p
+}
+
 // 路由组功能
 type RouterGroup struct {
 
@@ -76,6 +129,37 @@ type RouterGroup struct {
 	middlewares []MiddlewareHandleFunc
 }
 
+// WebSocket处理函数，返回后连接自动关闭
+type WSHandleFunc func(ctx *wcontext.Context, conn *websocket.Conn)
+
+// 注册WebSocket路由：中间件在握手前执行，可用于鉴权；握手失败时返回对应的错误响应
+//
+//	g.WS("/echo", func(ctx *wcontext.Context, conn *websocket.Conn) {
+//		for {
+//			mt, data, err := conn.ReadMessage()
+//			if err != nil {
+//				return
+//			}
+//			conn.WriteMessage(mt, data)
+//		}
+//	})
+func (r *RouterGroup) WS(pattern string, handler WSHandleFunc, handleChains ...MiddlewareHandleFunc) {
+	r.WSWithOptions(pattern, nil, handler, handleChains...)
+}
+
+// 同WS，可设置读取上限、压缩、子协议、跨域校验等
+func (r *RouterGroup) WSWithOptions(pattern string, opts *websocket.Options, handler WSHandleFunc, handleChains ...MiddlewareHandleFunc) {
+	r.GET(pattern, func(ctx *wcontext.Context) {
+		conn, err := ctx.Upgrade(opts)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		handler(ctx, conn)
+	}, handleChains...)
+}
+
+// 创建路由组，服务启动后调用会panic（REGISTER_AFTER_START）
 func (r *RouterGroup) Group(prefix string) *RouterGroup {
 
 	//保险起见，要对prefix进行校验
@@ -95,12 +179,78 @@ func (r *RouterGroup) Group(prefix string) *RouterGroup {
 
 	return group
 }
-func (r *RouterGroup) Run(addr string) {
-	(*(r.engine)).Start(addr)
+
+// 启动服务并阻塞，接收到终止信号（例如Ctrl+C）后优雅关闭
+// 接收到升级信号（SIGUSR2）时把监听交给新启动的进程，新进程就绪后优雅关闭当前进程
+func (r *RouterGroup) Run(addr string) error {
+	return run(*r.engine, addr)
+}
+
+// Run的实现，HttpServer和VHost共用
+func run(engine runner, addr string) error {
+	if err := engine.Start(addr); err != nil {
+		return err
+	}
+
+	// signal.Notify不会阻塞发送，必须使用带缓冲的chan
+	quitSig := make(chan os.Signal, 1)
+	signal.Notify(quitSig, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, upgradeSignals...)...)
+	defer signal.Stop(quitSig)
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- engine.Wait()
+	}()
+
+	for quit := false; !quit; {
+		select {
+		case err := <-serveErr:
+			// 服务异常退出，仍然执行关闭钩子
+			return errors.Join(err, engine.Stop())
+		case sig := <-quitSig:
+			engine.Logger().Info("signal received", "signal", sig.String())
+			quit = !isUpgradeSignal(sig)
+			if !quit {
+				// 升级失败则继续服务
+				if err := engine.Upgrade(); err != nil {
+					engine.Logger().Error("upgrade failed", "error", err)
+				} else {
+					quit = true
+				}
+			}
+		}
+	}
+
+	if err := engine.Stop(); err != nil {
+		return err
+	}
+	return <-serveErr
+}
+
+// 可以被Run启动和关闭的服务
+type runner interface {
+	Start(addr string) error
+	Stop() error
+	Wait() error
+	Upgrade() error
+	Logger() *slog.Logger
+}
+
+func isUpgradeSignal(sig os.Signal) bool {
+	for _, upgradeSig := range upgradeSignals {
+		if sig == upgradeSig {
+			return true
+		}
+	}
+	return false
 }
 
 // 注册中间件
-// 将中间件维护在当前路由组
+// 将中间件维护在当前路由组，服务启动后调用会panic（REGISTER_AFTER_START）
 func (r *RouterGroup) Use(middlewares ...MiddlewareHandleFunc) {
+	(*r.engine).mustNotStarted()
 	r.middlewares = append(r.middlewares, middlewares...)
+
+	// 已注册的路由需要重新编译处理链
+	(*r.engine).compileRoutes()
 }
@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net"
+)
+
+// 生命周期钩子
+
+// 开始监听之前执行，返回错误则中止启动
+type StartHook func() error
+
+// 监听成功后执行，addr为实际绑定的地址（例如":0"时分配的端口），返回错误则中止启动
+type ReadyHook func(addr net.Addr) error
+
+// 开始关闭时执行（排空请求之前）
+type StopHook func(ctx context.Context) error
+
+// 注册路由时执行
+type RouteHook func(method string, pattern string)
+
+// 注册路由组时执行
+type GroupHook func(prefix string)
+
+type lifecycle struct {
+	startHooks []StartHook
+	readyHooks []ReadyHook
+	stopHooks  []StopHook
+	routeHooks []RouteHook
+	groupHooks []GroupHook
+}
+
+// 注册启动前钩子（例如预热缓存）
+func (h *HttpServer) OnStart(hooks ...StartHook) {
+	h.startHooks = append(h.startHooks, hooks...)
+}
+
+// 注册监听成功钩子（例如写入服务发现文件）
+func (h *HttpServer) OnReady(hooks ...ReadyHook) {
+	h.readyHooks = append(h.readyHooks, hooks...)
+}
+
+// 注册关闭钩子，在就绪状态切换后、排空请求之前按注册顺序执行（例如从服务发现中注销）
+func (h *HttpServer) OnStop(hooks ...StopHook) {
+	h.stopHooks = append(h.stopHooks, hooks...)
+}
+
+// 注册路由钩子，此后每注册一个路由都会执行（例如生成接口文档）
+func (h *HttpServer) OnRouteRegistered(hooks ...RouteHook) {
+	h.routeHooks = append(h.routeHooks, hooks...)
+}
+
+// 注册路由组钩子，此后每创建一个路由组都会执行
+func (h *HttpServer) OnGroupRegistered(hooks ...GroupHook) {
+	h.groupHooks = append(h.groupHooks, hooks...)
+}
+
+func (l *lifecycle) runStart() error {
+	for _, hook := range l.startHooks {
+		if err := hook(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *lifecycle) runReady(addr net.Addr) error {
+	for _, hook := range l.readyHooks {
+		if err := hook(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (l *lifecycle) runStop(ctx context.Context) []error {
+	var errs []error
+	for _, hook := range l.stopHooks {
+		if err := hook(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+func (l *lifecycle) runRoute(method string, pattern string) {
+	for _, hook := range l.routeHooks {
+		hook(method, pattern)
+	}
+}
+
+func (l *lifecycle) runGroup(prefix string) {
+	for _, hook := range l.groupHooks {
+		hook(prefix)
+	}
+}
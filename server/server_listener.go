@@ -0,0 +1,216 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 监听状态，多个监听共用同一个http.Server和同一次优雅关闭
+type listenerState struct {
+	mu sync.Mutex
+
+	// 启动钩子是否已执行
+	started bool
+
+	// 启动后置为true，此后不能再注册路由和中间件（处理链在请求中无锁读取）
+	sealed atomic.Bool
+
+	// 正在服务的监听
+	listeners []net.Listener
+
+	// 监听的标识，与listeners一一对应，热升级时交给子进程
+	listenKeys []string
+
+	// 是否设置SO_REUSEPORT
+	reusePort bool
+
+	// 热升级等待子进程就绪的时间
+	upgradeTimeout time.Duration
+
+	// 替换请求入口（VHost按Host分发），为空时使用HttpServer自身
+	handler http.Handler
+
+	// 替换dry-run输出的路由表（VHost输出各主机的路由），为空时使用RouteTable
+	routeTable func() []RouteInfo
+
+	// 内部服务（独立端口的管理端），dry-run时不输出路由表
+	internal bool
+
+	// 每个监听一个服务协程
+	wg sync.WaitGroup
+
+	// 服务异常退出的原因
+	serveErrs []error
+}
+
+// 执行启动钩子，多个监听只执行一次
+func (h *HttpServer) prepare() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.started {
+		return nil
+	}
+	if err := h.dryRun(); err != nil {
+		return err
+	}
+	if err := h.runStart(); err != nil {
+		return err
+	}
+	if err := h.tuning.loadCertificate(); err != nil {
+		return err
+	}
+	h.started = true
+	h.sealed.Store(true)
+	return nil
+}
+
+// 获取（必要时创建）共用的http.Server
+func (h *HttpServer) httpServer() *http.Server {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.serv == nil {
+		var handler http.Handler = h
+		if h.handler != nil {
+			handler = h.handler
+		}
+		h.serv = &http.Server{
+			Handler: handler,
+		}
+		h.tuning.apply(h.serv)
+		h.serv.ErrorLog = h.errorLog()
+	}
+	return h.serv
+}
+
+func (h *HttpServer) currentServer() *http.Server {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.serv
+}
+
+// 在给定的监听上提供服务（非阻塞）
+// 可以多次调用，在多个监听上同时提供服务，例如公网TCP端口加本地Unix socket
+// 关闭服务时会一并关闭所有监听
+func (h *HttpServer) Serve(listener net.Listener) error {
+	return h.serve(listener)
+}
+
+func (h *HttpServer) serve(listener net.Listener) error {
+	if err := h.prepare(); err != nil {
+		listener.Close()
+		return err
+	}
+	if err := h.runReady(listener.Addr()); err != nil {
+		listener.Close()
+		return err
+	}
+
+	serv := h.httpServer()
+
+	h.mu.Lock()
+	h.listeners = append(h.listeners, listener)
+	h.listenKeys = append(h.listenKeys, listenKey(listener))
+	h.mu.Unlock()
+
+	h.wg.Add(1)
+	h.markReady()
+	h.logger.Info("server listening", "network", listener.Addr().Network(), "addr", listener.Addr().String())
+
+	go func() {
+		defer h.wg.Done()
+		// Shutdown后Serve会立即返回ErrServerClosed，属于正常关闭
+		if err := h.serveListener(serv, listener); !errors.Is(err, http.ErrServerClosed) {
+			h.mu.Lock()
+			h.serveErrs = append(h.serveErrs, err)
+			h.mu.Unlock()
+		}
+	}()
+
+	// 作为热升级的子进程启动时，继承的监听全部开始服务后通知父进程
+	inherited.notify()
+	return nil
+}
+
+// 配置了证书时使用HTTPS，证书已在prepare中加载到TLSConfig
+func (h *HttpServer) serveListener(serv *http.Server, listener net.Listener) error {
+	if serv.TLSConfig != nil {
+		return serv.ServeTLS(listener, "", "")
+	}
+	return serv.Serve(listener)
+}
+
+// 创建监听，优先认领从父进程继承的同地址监听
+func (h *HttpServer) listen(network string, addr string) (net.Listener, error) {
+	if listener := inherited.take(network, addr); listener != nil {
+		return listener, nil
+	}
+	if network == "unix" {
+		if err := removeStaleSocket(addr); err != nil {
+			return nil, err
+		}
+	}
+	config := net.ListenConfig{}
+	if h.reusePort && network != "unix" {
+		config.Control = reusePortControl
+	}
+	return config.Listen(context.Background(), network, addr)
+}
+
+// 清理上次未正常退出遗留的socket文件，路径上是其他文件时返回错误，避免误删
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%w: %s", UNIX_PATH_NOT_SOCKET, path)
+	}
+	return os.Remove(path)
+}
+
+// 在Unix domain socket上提供服务（非阻塞），perm为socket文件的权限
+func (h *HttpServer) StartUnix(path string, perm os.FileMode) error {
+	if err := h.prepare(); err != nil {
+		return err
+	}
+	listener, err := h.listen("unix", path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		listener.Close()
+		return err
+	}
+	return h.serve(listener)
+}
+
+// 获取所有监听实际绑定的地址
+func (h *HttpServer) Addrs() []net.Addr {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	addrs := make([]net.Addr, 0, len(h.listeners))
+	for _, listener := range h.listeners {
+		addrs = append(addrs, listener.Addr())
+	}
+	return addrs
+}
+
+// 阻塞直到所有监听的服务退出，正常关闭时返回nil
+// 作为热升级的子进程启动时，调用Wait表示所有监听都已启动，此时通知父进程并关闭未认领的继承监听
+func (h *HttpServer) Wait() error {
+	inherited.release()
+	h.wg.Wait()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return errors.Join(h.serveErrs...)
+}
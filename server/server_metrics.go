@@ -0,0 +1,18 @@
+package server
+
+import "github.com/asxlwsl/weber/metrics"
+
+// 指标的默认路径
+const DefaultMetricsPath = "/metrics"
+
+// 启用请求指标，并在path（为空时为/metrics）上以Prometheus文本格式输出
+// 统计中间件位于Flush之前，记录实际写出的状态码和响应大小；返回的Metrics可以注册自定义指标
+func (h *HttpServer) EnableMetrics(path string) *metrics.Metrics {
+	if path == "" {
+		path = DefaultMetricsPath
+	}
+	m := metrics.New()
+	h.UseBeforeFlush(m.Middleware())
+	h.GET(path, m.Handle)
+	return m
+}
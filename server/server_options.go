@@ -0,0 +1,159 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"time"
+)
+
+// 默认值面向生产环境：限制读取请求头的时间，避免Slowloris攻击
+// 写超时默认不限制，流式响应（SSE、大文件下载）需要长时间写入
+const (
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultReadTimeout       = 60 * time.Second
+	DefaultWriteTimeout      = 0
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20
+)
+
+// http.Server的可调参数
+type serverTuning struct {
+	readTimeout       time.Duration
+	readHeaderTimeout time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+
+	errorLog    *log.Logger
+	connState   func(net.Conn, http.ConnState)
+	baseContext func(net.Listener) context.Context
+	connContext func(ctx context.Context, conn net.Conn) context.Context
+
+	// 证书文件，启动时加载到tlsConfig
+	certFile string
+	keyFile  string
+
+	// 设置后所有监听都使用HTTPS
+	tlsConfig *tls.Config
+}
+
+func defaultServerTuning() serverTuning {
+	return serverTuning{
+		readTimeout:       DefaultReadTimeout,
+		readHeaderTimeout: DefaultReadHeaderTimeout,
+		writeTimeout:      DefaultWriteTimeout,
+		idleTimeout:       DefaultIdleTimeout,
+		maxHeaderBytes:    DefaultMaxHeaderBytes,
+	}
+}
+
+// 将参数应用到http.Server
+func (t *serverTuning) apply(serv *http.Server) {
+	serv.ReadTimeout = t.readTimeout
+	serv.ReadHeaderTimeout = t.readHeaderTimeout
+	serv.WriteTimeout = t.writeTimeout
+	serv.IdleTimeout = t.idleTimeout
+	serv.MaxHeaderBytes = t.maxHeaderBytes
+	serv.ErrorLog = t.errorLog
+	serv.ConnState = t.connState
+	serv.BaseContext = t.baseContext
+	serv.ConnContext = t.connContext
+	serv.TLSConfig = t.tlsConfig
+}
+
+// 加载证书文件
+func (t *serverTuning) loadCertificate() error {
+	if t.certFile == "" && t.keyFile == "" {
+		return nil
+	}
+	cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+	if err != nil {
+		return err
+	}
+	if t.tlsConfig == nil {
+		t.tlsConfig = &tls.Config{}
+	}
+	t.tlsConfig.Certificates = append(t.tlsConfig.Certificates, cert)
+	return nil
+}
+
+// 使用证书文件提供HTTPS服务
+func WithTLS(certFile string, keyFile string) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.certFile = certFile
+		h.tuning.keyFile = keyFile
+	}
+}
+
+// 使用自定义的TLS配置提供HTTPS服务（例如GetCertificate动态选择证书）
+func WithTLSConfig(config *tls.Config) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.tlsConfig = config
+	}
+}
+
+// 读取整个请求（包括请求体）的超时时间，0表示不限制
+func WithReadTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.readTimeout = timeout
+	}
+}
+
+// 读取请求头的超时时间，0表示使用ReadTimeout
+func WithReadHeaderTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.readHeaderTimeout = timeout
+	}
+}
+
+// 写入响应的超时时间，0表示不限制
+func WithWriteTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.writeTimeout = timeout
+	}
+}
+
+// keep-alive连接的空闲超时时间，0表示使用ReadTimeout
+func WithIdleTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.idleTimeout = timeout
+	}
+}
+
+// 请求头的最大字节数
+func WithMaxHeaderBytes(size int) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.maxHeaderBytes = size
+	}
+}
+
+// 设置net/http内部错误（例如TLS握手失败）的日志
+func WithErrorLog(logger *log.Logger) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.errorLog = logger
+	}
+}
+
+// 连接状态变化时的回调，可用于统计连接数
+func WithConnState(fn func(net.Conn, http.ConnState)) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.connState = fn
+	}
+}
+
+// 设置每个监听的基础上下文，所有请求的上下文都派生自它
+func WithBaseContext(fn func(net.Listener) context.Context) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.baseContext = fn
+	}
+}
+
+// 每个新连接执行一次，返回的上下文作为该连接上所有请求的上下文
+func WithConnContext(fn func(ctx context.Context, conn net.Conn) context.Context) HttpOption {
+	return func(h *HttpServer) {
+		h.tuning.connContext = fn
+	}
+}
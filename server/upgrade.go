@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 零停机重启（热升级）
+// 父进程把监听的文件描述符通过ExtraFiles交给新启动的子进程，
+// 子进程在同一地址上开始服务后通过管道通知父进程，父进程随后排空请求并退出
+
+const (
+	// 继承的监听，按文件描述符顺序（从3开始）用逗号分隔，例如 tcp://[::]:8080,unix:///run/app.sock
+	ENV_LISTEN_FDS = "WEBER_LISTEN_FDS"
+
+	// 子进程就绪后写入的管道
+	ENV_READY_FD = "WEBER_READY_FD"
+
+	// 默认等待子进程就绪的时间
+	DefaultUpgradeTimeout = 30 * time.Second
+)
+
+// 监听的标识，按实际绑定的地址生成，例如 tcp://[::]:8080
+func listenKey(listener net.Listener) string {
+	return listener.Addr().Network() + "://" + listener.Addr().String()
+}
+
+// 从父进程继承的监听
+type inheritance struct {
+	once sync.Once
+	mu   sync.Mutex
+
+	// 尚未认领的监听，按文件描述符顺序
+	listeners []net.Listener
+
+	// 通知父进程的管道
+	ready *os.File
+}
+
+var inherited inheritance
+
+func (i *inheritance) load() {
+	i.once.Do(func() {
+		keys := os.Getenv(ENV_LISTEN_FDS)
+		readyFd := os.Getenv(ENV_READY_FD)
+
+		// 只继承一次，避免再次升级时被孙进程误用
+		os.Unsetenv(ENV_LISTEN_FDS)
+		os.Unsetenv(ENV_READY_FD)
+
+		if keys == "" {
+			return
+		}
+		for idx, key := range strings.Split(keys, ",") {
+			file := os.NewFile(uintptr(3+idx), key)
+			listener, err := net.FileListener(file)
+			file.Close()
+			if err != nil {
+				continue
+			}
+			i.listeners = append(i.listeners, listener)
+		}
+		if fd, err := strconv.Atoi(readyFd); err == nil {
+			i.ready = os.NewFile(uintptr(fd), "ready")
+		}
+	})
+}
+
+// 认领与请求的地址相同的继承监听，不存在时返回nil
+// 父进程可能以:8080启动也可能直接传入监听，因此按实际绑定的地址比较，端口为0时认领任意端口
+func (i *inheritance) take(network string, addr string) net.Listener {
+	i.load()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for idx, listener := range i.listeners {
+		if sameAddr(listener.Addr(), network, addr) {
+			i.listeners = append(i.listeners[:idx], i.listeners[idx+1:]...)
+			return listener
+		}
+	}
+	return nil
+}
+
+func sameAddr(bound net.Addr, network string, addr string) bool {
+	if bound.Network() != network {
+		return false
+	}
+	if bound.String() == addr {
+		return true
+	}
+	tcpAddr, ok := bound.(*net.TCPAddr)
+	if !ok {
+		return false
+	}
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if portNum, err := net.LookupPort(network, port); err != nil || (portNum != 0 && portNum != tcpAddr.Port) {
+		return false
+	}
+	if host == "" {
+		return tcpAddr.IP.IsUnspecified()
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return false
+	}
+	for _, ip := range ips {
+		if ip.Equal(tcpAddr.IP) || ip.IsUnspecified() && tcpAddr.IP.IsUnspecified() {
+			return true
+		}
+	}
+	return false
+}
+
+// 继承的监听都已认领时通知父进程
+func (i *inheritance) notify() {
+	i.load()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if len(i.listeners) == 0 {
+		i.signal()
+	}
+}
+
+// 子进程自己的监听都已开始服务（进入Wait）时通知父进程，关闭不再使用的继承监听
+func (i *inheritance) release() {
+	i.load()
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, listener := range i.listeners {
+		listener.Close()
+	}
+	i.listeners = nil
+	i.signal()
+}
+
+func (i *inheritance) signal() {
+	if i.ready == nil {
+		return
+	}
+	i.ready.Write([]byte{1})
+	i.ready.Close()
+	i.ready = nil
+}
+
+// 设置SO_REUSEPORT，允许多个进程绑定同一端口
+func WithReusePort() HttpOption {
+	return func(h *HttpServer) {
+		h.reusePort = true
+	}
+}
+
+// 设置热升级时等待子进程就绪的时间
+func WithUpgradeTimeout(timeout time.Duration) HttpOption {
+	return func(h *HttpServer) {
+		h.upgradeTimeout = timeout
+	}
+}
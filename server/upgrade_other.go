@@ -0,0 +1,11 @@
+//go:build !unix
+
+package server
+
+import "os"
+
+var upgradeSignals []os.Signal
+
+func (h *HttpServer) Upgrade() error {
+	return UPGRADE_UNSUPPORTED
+}
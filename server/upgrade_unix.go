@@ -0,0 +1,115 @@
+//go:build unix
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// 触发热升级的信号
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
+
+// 热升级：启动新的可执行文件并把所有监听交给它
+// 返回nil表示子进程已经在所有监听上开始服务，调用方随后应优雅关闭当前进程
+func (h *HttpServer) Upgrade() error {
+	h.mu.Lock()
+	listeners := append([]net.Listener(nil), h.listeners...)
+	keys := append([]string(nil), h.listenKeys...)
+	h.mu.Unlock()
+
+	files := make([]*os.File, 0, len(listeners))
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+	for _, listener := range listeners {
+		filer, ok := listener.(interface{ File() (*os.File, error) })
+		if !ok {
+			return fmt.Errorf("listener %s can't be inherited", listener.Addr())
+		}
+		file, err := filer.File()
+		if err != nil {
+			return err
+		}
+		files = append(files, file)
+	}
+
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer readyR.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		readyW.Close()
+		return err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = append(os.Environ(),
+		ENV_LISTEN_FDS+"="+strings.Join(keys, ","),
+		ENV_READY_FD+"="+strconv.Itoa(3+len(files)),
+	)
+	cmd.ExtraFiles = append(append([]*os.File(nil), files...), readyW)
+
+	err = cmd.Start()
+	readyW.Close()
+	// 传给子进程时File.Fd()会把socket设为阻塞模式，阻塞模式与当前进程的监听共用，
+	// 不恢复的话当前进程的Accept会阻塞在系统调用中，关闭监听时无法返回
+	for _, file := range files {
+		setNonblock(file)
+	}
+	if err != nil {
+		return err
+	}
+
+	// 子进程就绪时写入一个字节；子进程提前退出时读到EOF
+	result := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 1)
+		n, _ := readyR.Read(buf)
+		result <- n == 1
+	}()
+
+	timer := time.NewTimer(h.upgradeTimeout)
+	defer timer.Stop()
+
+	ok := false
+	select {
+	case ok = <-result:
+	case <-timer.C:
+	}
+	if !ok {
+		cmd.Process.Kill()
+		cmd.Wait()
+		return UPGRADE_NOT_READY
+	}
+
+	// 子进程接管了socket文件，当前进程关闭时不能删除
+	for _, listener := range listeners {
+		if unixListener, ok := listener.(*net.UnixListener); ok {
+			unixListener.SetUnlinkOnClose(false)
+		}
+	}
+	go cmd.Wait()
+	return nil
+}
+
+func setNonblock(file *os.File) {
+	if conn, err := file.SyscallConn(); err == nil {
+		conn.Control(func(fd uintptr) {
+			syscall.SetNonblock(int(fd), true)
+		})
+	}
+}
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 虚拟主机：一组监听按Host请求头分发到不同的HttpServer
+// 每个HttpServer保留自己的路由、路由组和中间件，共用监听、TLS证书选择（SNI）和优雅关闭
+//
+//	vhost := server.NewVHost(server.WithShutdownTimeout(10 * time.Second))
+//	vhost.Handle("a.example.com", siteA)
+//	vhost.Handle("*.example.com", siteB)
+//	vhost.Default(landing)
+//	vhost.Run(":443")
+type VHost struct {
+	// 负责监听的服务，请求入口替换为VHost
+	front *HttpServer
+
+	mu sync.RWMutex
+
+	// 精确匹配的主机名
+	hosts map[string]*HttpServer
+
+	// 通配主机名，键为去掉*的后缀，例如 .example.com
+	wildcards map[string]*HttpServer
+
+	// 未匹配到主机时使用
+	fallback *HttpServer
+}
+
+// options作用于负责监听的服务（超时、TLS、热升级等）
+func NewVHost(options ...HttpOption) *VHost {
+	v := &VHost{
+		front:     NewHttpServer(options...),
+		hosts:     make(map[string]*HttpServer),
+		wildcards: make(map[string]*HttpServer),
+	}
+	v.front.handler = v
+	v.front.routeTable = v.routeTable
+
+	v.front.OnStart(v.prepare)
+	v.front.OnReady(v.ready)
+	v.front.OnStop(v.beginShutdown)
+	v.front.OnShutdown(v.finishShutdown)
+	return v
+}
+
+// 注册主机，host为主机名（example.com）或通配主机名（*.example.com）
+func (v *VHost) Handle(host string, server *HttpServer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	host = strings.ToLower(host)
+	if strings.HasPrefix(host, "*.") {
+		v.wildcards[host[1:]] = server
+		return
+	}
+	v.hosts[host] = server
+}
+
+// 设置默认主机，未匹配到任何主机时使用
+func (v *VHost) Default(server *HttpServer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.fallback = server
+}
+
+// 按主机名查找服务：精确匹配 -> 最长的通配后缀 -> 默认主机
+func (v *VHost) match(host string) *HttpServer {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	host = strings.ToLower(host)
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.TrimSuffix(host, ".")
+
+	if server, ok := v.hosts[host]; ok {
+		return server
+	}
+
+	var matched *HttpServer
+	matchedLen := 0
+	for suffix, server := range v.wildcards {
+		if strings.HasSuffix(host, suffix) && len(suffix) > matchedLen {
+			matched, matchedLen = server, len(suffix)
+		}
+	}
+	if matched != nil {
+		return matched
+	}
+	return v.fallback
+}
+
+// 所有注册的服务（去重）
+func (v *VHost) servers() []*HttpServer {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	seen := make(map[*HttpServer]bool)
+	servers := make([]*HttpServer, 0)
+	add := func(server *HttpServer) {
+		if server != nil && !seen[server] {
+			seen[server] = true
+			servers = append(servers, server)
+		}
+	}
+	for _, server := range v.hosts {
+		add(server)
+	}
+	for _, server := range v.wildcards {
+		add(server)
+	}
+	add(v.fallback)
+	return servers
+}
+
+// 各主机的路由表，按主机名排序，默认主机的主机名为*
+func (v *VHost) routeTable() []RouteInfo {
+	v.mu.RLock()
+	hosts := make(map[string]*HttpServer, len(v.hosts)+len(v.wildcards))
+	for host, server := range v.hosts {
+		hosts[host] = server
+	}
+	for suffix, server := range v.wildcards {
+		hosts["*"+suffix] = server
+	}
+	fallback := v.fallback
+	v.mu.RUnlock()
+
+	names := make([]string, 0, len(hosts))
+	for host := range hosts {
+		names = append(names, host)
+	}
+	sort.Strings(names)
+
+	table := make([]RouteInfo, 0)
+	add := func(host string, server *HttpServer) {
+		for _, route := range server.RouteTable() {
+			route.Host = host
+			table = append(table, route)
+		}
+	}
+	for _, host := range names {
+		add(host, hosts[host])
+	}
+	if fallback != nil {
+		add("*", fallback)
+	}
+	return table
+}
+
+func (v *VHost) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	server := v.match(request.Host)
+	if server == nil {
+		http.Error(writer, "404 NOT FOUND!", http.StatusNotFound)
+		return
+	}
+	server.ServeHTTP(writer, request)
+}
+
+// 启动前执行各服务的启动钩子并加载证书，有服务配置了证书时按SNI选择
+func (v *VHost) prepare() error {
+	useTLS := false
+	for _, server := range v.servers() {
+		if err := server.prepare(); err != nil {
+			return err
+		}
+		if server.tuning.tlsConfig != nil {
+			useTLS = true
+		}
+	}
+	if !useTLS {
+		return nil
+	}
+
+	config := v.front.tuning.tlsConfig
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	config.GetCertificate = v.certificate
+	v.front.tuning.tlsConfig = config
+	return nil
+}
+
+// 按SNI选择证书，返回nil时使用front自身配置的证书
+func (v *VHost) certificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	server := v.match(hello.ServerName)
+	if server == nil || server.tuning.tlsConfig == nil {
+		return nil, nil
+	}
+	config := server.tuning.tlsConfig
+	if config.GetCertificate != nil {
+		return config.GetCertificate(hello)
+	}
+	if len(config.Certificates) != 0 {
+		return &config.Certificates[0], nil
+	}
+	return nil, nil
+}
+
+func (v *VHost) ready(addr net.Addr) error {
+	for _, server := range v.servers() {
+		if err := server.runReady(addr); err != nil {
+			return err
+		}
+		server.markReady()
+	}
+	return nil
+}
+
+func (v *VHost) beginShutdown(ctx context.Context) error {
+	var errs []error
+	for _, server := range v.servers() {
+		errs = append(errs, server.beginShutdown(ctx)...)
+	}
+	return errors.Join(errs...)
+}
+
+func (v *VHost) finishShutdown(ctx context.Context) error {
+	var errs []error
+	for _, server := range v.servers() {
+		errs = append(errs, server.finishShutdown(ctx)...)
+	}
+	return errors.Join(errs...)
+}
+
+// 启动服务（非阻塞）
+func (v *VHost) Start(addr string) error {
+	return v.front.Start(addr)
+}
+
+// 在给定的监听上提供服务（非阻塞）
+func (v *VHost) Serve(listener net.Listener) error {
+	return v.front.Serve(listener)
+}
+
+// 在Unix domain socket上提供服务（非阻塞）
+func (v *VHost) StartUnix(path string, perm os.FileMode) error {
+	return v.front.StartUnix(path, perm)
+}
+
+// 启动服务并阻塞，接收到终止信号后优雅关闭所有主机
+func (v *VHost) Run(addr string) error {
+	return run(v, addr)
+}
+
+// 优雅关闭：排空所有连接，并执行各服务的关闭钩子
+func (v *VHost) Shutdown(ctx context.Context) error {
+	return v.front.Shutdown(ctx)
+}
+
+func (v *VHost) Stop() error {
+	return v.front.Stop()
+}
+
+func (v *VHost) Wait() error {
+	return v.front.Wait()
+}
+
+func (v *VHost) Upgrade() error {
+	return v.front.Upgrade()
+}
+
+// 负责监听的服务的框架日志
+func (v *VHost) Logger() *slog.Logger {
+	return v.front.logger
+}
+
+func (v *VHost) Addrs() []net.Addr {
+	return v.front.Addrs()
+}
@@ -4,9 +4,11 @@ import (
 	"encoding/json"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
 )
 
 // 请求处理函数
@@ -32,7 +34,7 @@ const (
 type Context struct {
 
 	// 响应体对象
-	response http.ResponseWriter
+	response responseWriter
 
 	// 请求体对象
 	request *http.Request
@@ -43,6 +45,9 @@ type Context struct {
 	// 请求URL
 	Pattern string
 
+	// 匹配到的路由模式，如 /user/:id，未匹配到路由时为空
+	routePattern string
+
 	//请求相关
 	// 1.param参数
 	Params map[string]string
@@ -73,6 +78,22 @@ type Context struct {
 	Done bool
 
 	Error error
+
+	// 当前的事件流，处理完成时关闭
+	sse *SSEStream
+
+	// 通过Set保存的数据
+	values map[string]any
+
+	// 服务容器
+	services ServiceResolver
+
+	// 框架日志和带有请求属性的日志（第一次调用Logger时创建）
+	logger        *slog.Logger
+	requestLogger *slog.Logger
+
+	// 请求ID
+	requestID string
 }
 
 // 获取params参数
@@ -126,6 +147,58 @@ func (c *Context) GetMethod() string {
 	return c.method
 }
 
+// 匹配到的路由模式，如 /user/:id，未匹配到路由时为空
+func (c *Context) RoutePattern() string {
+	return c.routePattern
+}
+
+// 由路由在匹配成功时设置
+func (c *Context) SetRoutePattern(pattern string) {
+	c.routePattern = pattern
+	c.requestLogger = nil
+}
+
+// 获取原始请求
+func (c *Context) Request() *http.Request {
+	return c.request
+}
+
+// 获取状态码，已直接写出时返回实际发送的状态码
+func (c *Context) GetStatusCode() int {
+	if c.response.wroteHeader {
+		return c.response.status
+	}
+	return c.status
+}
+
+// 最终响应的状态码，未设置时为200，用于日志、指标等
+func (c *Context) ResponseStatus() int {
+	if status := c.GetStatusCode(); status != 0 {
+		return status
+	}
+	return DEFAULT_CODE
+}
+
+// 切换为直接写出模式，返回的Writer写出的数据不经过缓冲
+// 第一次写入时发送状态码和SetResponseHeader设置的响应头，之后Complete不再写出缓冲的响应
+//
+//	ctx.SetResponseHeader("Content-Type", "application/octet-stream")
+//	io.Copy(ctx.Writer(), file)
+func (c *Context) Writer() ResponseWriter {
+	return &c.response
+}
+
+// 获取响应体
+func (c *Context) GetResponseBody() []byte {
+	return c.data
+}
+
+// 获取底层的响应头，用于设置同名的多个值（例如Set-Cookie）
+// SetResponseHeader设置的值在写出时会覆盖这里的同名响应头
+func (c *Context) ResponseHeader() http.Header {
+	return c.response.Header()
+}
+
 // 设置状态码
 func (c *Context) SetStatusCode(code int) {
 	c.status = code
@@ -173,20 +246,19 @@ func (c *Context) TEXT(text string) {
 }
 
 // 处理完成，写入响应数据
+// 已通过Writer直接写出、切换为事件流或连接被接管时不再写入
 func (c *Context) Complete() {
-
-	//写入响应头(先写响应头才会生效)
-	for key, value := range c.header {
-		c.response.Header().Set(key, value)
+	if c.sse != nil {
+		c.sse.Close()
 	}
-
-	//写入状态码
-	if c.status != 0 {
-		c.response.WriteHeader(c.status)
-	} else {
-		c.response.WriteHeader(DEFAULT_CODE)
+	if c.response.Written() {
+		c.Done = true
+		return
 	}
 
+	//写入状态码和响应头(先写响应头才会生效)
+	c.response.writeHeaderNow()
+
 	//写入响应数据
 	c.response.Write(c.data)
 	c.Done = true
@@ -197,19 +269,120 @@ func (c *Context) Fail(statusCode int, msg string) {
 }
 func NewContext(w http.ResponseWriter, r *http.Request) *Context {
 	ctx := &Context{
-		response: w,
-		request:  r,
-		method:   r.Method,
-		Pattern:  r.URL.Path,
-		header:   make(map[string]string),
-		handlers: []HandleFunc{},
-		index:    -1,
-		Done:     false,
-	}
-	parseQueryParams(ctx)
+		header: make(map[string]string),
+		Params: make(map[string]string),
+	}
+	ctx.response.ctx = ctx
+	ctx.reset(w, r)
 	return ctx
 }
 
+// 上下文对象池，减少每个请求的内存分配
+var contextPool = sync.Pool{
+	New: func() any {
+		return NewContext(nil, nil)
+	},
+}
+
+// 从对象池获取上下文
+// 处理函数返回后上下文会被回收复用，不能在其他协程中继续持有，需要时使用Copy
+func AcquireContext(w http.ResponseWriter, r *http.Request) *Context {
+	ctx := contextPool.Get().(*Context)
+	ctx.reset(w, r)
+	return ctx
+}
+
+// 将上下文放回对象池，调用后不能再使用ctx
+func ReleaseContext(ctx *Context) {
+	ctx.reset(nil, nil)
+	contextPool.Put(ctx)
+}
+
+// 重置上下文，复用header和Params的map
+func (c *Context) reset(w http.ResponseWriter, r *http.Request) {
+	c.response.reset(w)
+	c.request = r
+	c.method = ""
+	c.Pattern = ""
+	c.routePattern = ""
+	c.cacheQuery = nil
+	c.cacheBody = nil
+	c.status = 0
+	for key := range c.header {
+		delete(c.header, key)
+	}
+	c.data = nil
+	c.handlers = c.handlers[:0]
+	c.index = -1
+	c.Done = false
+	c.Error = nil
+	c.sse = nil
+	c.services = nil
+	c.logger = nil
+	c.requestLogger = nil
+	c.requestID = ""
+	for key := range c.values {
+		delete(c.values, key)
+	}
+
+	for key := range c.Params {
+		delete(c.Params, key)
+	}
+	if r != nil {
+		c.method = r.Method
+		c.Pattern = r.URL.Path
+		parseQueryParams(c)
+	}
+}
+
+// 复制一份只读的上下文，用于在处理函数返回后仍需使用的协程
+// 副本不参与对象池，写入的响应会被丢弃
+func (c *Context) Copy() *Context {
+	cp := &Context{
+		request:      c.request,
+		method:       c.method,
+		Pattern:      c.Pattern,
+		routePattern: c.routePattern,
+		Params:       make(map[string]string, len(c.Params)),
+		status:       c.status,
+		header:       make(map[string]string, len(c.header)),
+		data:         c.data,
+		index:        -1,
+		Done:         c.Done,
+		Error:        c.Error,
+
+		logger:        c.logger,
+		requestLogger: c.requestLogger,
+		requestID:     c.requestID,
+	}
+	for key, value := range c.Params {
+		cp.Params[key] = value
+	}
+	for key, value := range c.header {
+		cp.header[key] = value
+	}
+	for key, value := range c.values {
+		cp.Set(key, value)
+	}
+	cp.response = responseWriter{ResponseWriter: discardResponse{header: http.Header{}}, ctx: cp}
+	return cp
+}
+
+// 丢弃写入数据的ResponseWriter
+type discardResponse struct {
+	header http.Header
+}
+
+func (d discardResponse) Header() http.Header {
+	return d.header
+}
+
+func (d discardResponse) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (d discardResponse) WriteHeader(statusCode int) {}
+
 // 执行所有视图函数
 func (c *Context) Next() {
 	c.index++
@@ -221,7 +394,10 @@ func (c *Context) Next() {
 
 func parseQueryParams(ctx *Context) {
 	querys := ctx.request.URL.RawQuery
-	params := map[string]string{}
+	if querys == "" {
+		return
+	}
+	params := ctx.Params
 	paramSets := strings.Split(strings.Trim(querys, "?"), "&")
 
 	for _, paramSet := range paramSets {
@@ -243,6 +419,4 @@ func parseQueryParams(ctx *Context) {
 		}
 
 	}
-
-	ctx.Params = params
 }
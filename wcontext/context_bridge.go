@@ -0,0 +1,55 @@
+package wcontext
+
+import "net/http"
+
+// 与net/http的处理函数、中间件互通，见server.WrapHandler、server.WrapMiddleware
+
+// 用net/http的Handler处理当前请求
+// handler写出的响应会同步到上下文（Writer().Written()、GetStatusCode()），之后缓冲的响应不再写出
+// handler可以通过FromRequest取回上下文
+func (c *Context) ServeThrough(handler http.Handler) {
+	outer := &responseWriter{ResponseWriter: c.response.ResponseWriter, ctx: c}
+	handler.ServeHTTP(outer, c.BindRequest())
+
+	// 内层通过ServeWith写出时，上下文已记录了写出的状态
+	if outer.Written() && !c.response.Written() {
+		c.response.wroteHeader = outer.wroteHeader
+		c.response.hijacked = outer.hijacked
+		c.response.status = outer.status
+		c.response.size = outer.size
+	}
+}
+
+// 在net/http中间件传入的w、r上继续执行fn
+// w被中间件包装时，期间上下文写出的响应都经过w（例如被压缩、被记录状态码），fn返回后缓冲的响应也立即写入w
+// w未被包装时响应继续缓冲，外层的中间件仍可修改
+func (c *Context) ServeWith(w http.ResponseWriter, r *http.Request, fn HandleFunc) {
+	previousWriter, previousRequest := c.response.ResponseWriter, c.request
+	wrapped := !c.ownsWriter(w)
+	if wrapped {
+		c.response.ResponseWriter = w
+	}
+	c.request = r
+	defer func() {
+		c.response.ResponseWriter = previousWriter
+		c.request = previousRequest
+	}()
+
+	fn(c)
+	if wrapped {
+		c.Complete()
+	}
+}
+
+// w是否为ServeThrough传给中间件的原始ResponseWriter
+func (c *Context) ownsWriter(w http.ResponseWriter) bool {
+	outer, ok := w.(*responseWriter)
+	return ok && outer.ctx == c
+}
+
+// 复制from中通过Set保存的数据
+func (c *Context) CopyValues(from *Context) {
+	for key, value := range from.values {
+		c.Set(key, value)
+	}
+}
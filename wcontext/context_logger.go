@@ -0,0 +1,61 @@
+package wcontext
+
+import (
+	"log/slog"
+	"net"
+)
+
+// 上游（负载均衡、网关）传入请求ID的请求头
+const REQUEST_ID_HEADER = "X-Request-ID"
+
+// 框架日志，由服务在处理请求前设置
+func (c *Context) SetLogger(logger *slog.Logger) {
+	c.logger = logger
+	c.requestLogger = nil
+}
+
+// 当前请求的日志，已带有request_id、route和client_ip属性
+// 未设置日志时使用slog.Default()
+//
+//	ctx.Logger().Info("order created", "order_id", order.ID)
+func (c *Context) Logger() *slog.Logger {
+	if c.requestLogger != nil {
+		return c.requestLogger
+	}
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	attrs := make([]any, 0, 3)
+	if id := c.RequestID(); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if c.routePattern != "" {
+		attrs = append(attrs, slog.String("route", c.routePattern))
+	}
+	if c.request != nil {
+		attrs = append(attrs, slog.String("client_ip", c.ClientIP()))
+	}
+	c.requestLogger = logger.With(attrs...)
+	return c.requestLogger
+}
+
+// 请求ID，由middleware.RequestID校验或生成后通过SetRequestID设置，未设置时为空
+// 不直接读取请求头，避免客户端伪造的值（如含换行）进入日志
+func (c *Context) RequestID() string {
+	return c.requestID
+}
+
+func (c *Context) SetRequestID(id string) {
+	c.requestID = id
+	c.requestLogger = nil
+}
+
+// 客户端IP（不含端口），取自连接的远端地址
+func (c *Context) ClientIP() string {
+	addr := c.request.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,17 @@
+package wcontext
+
+import "reflect"
+
+// 服务容器，由服务在处理请求前设置，处理函数通过weber.Resolve获取服务
+type ServiceResolver interface {
+	Resolve(ctx *Context, key reflect.Type) (any, error)
+}
+
+func (c *Context) SetServices(services ServiceResolver) {
+	c.services = services
+}
+
+// 当前请求可用的服务容器，未设置时为nil
+func (c *Context) Services() ServiceResolver {
+	return c.services
+}
@@ -0,0 +1,197 @@
+package wcontext
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的心跳间隔，防止代理因连接空闲而断开
+const DefaultSSEKeepAlive = 15 * time.Second
+
+var (
+	SSE_CLOSED = errors.New("sse stream closed!")
+)
+
+// Server-Sent Events 事件流
+// 由ctx.SSE()创建，处理函数返回后自动关闭；发送是并发安全的
+type SSEStream struct {
+	response   ResponseWriter
+	controller *http.ResponseController
+
+	// 请求的上下文，客户端断开时取消
+	reqCtx context.Context
+
+	// 客户端重连时携带的最后一个事件ID
+	lastEventID string
+
+	mu     sync.Mutex
+	closed bool
+	err    error
+
+	// 客户端断开或事件流关闭时取消，创建时派生一次，Done()直接返回
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	keepAlive chan time.Duration
+}
+
+// 将当前请求切换为事件流，写出响应头后即可持续发送事件
+// 基于ctx.Writer()，已通过SetResponseHeader设置的响应头会一并写出，之后缓冲的响应不再写出
+func (c *Context) SSE() *SSEStream {
+	if c.sse != nil {
+		return c.sse
+	}
+
+	writer := c.Writer()
+	header := writer.Header()
+	header.Set(CONTENT_TYPE, "text/event-stream; charset=utf-8")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// 关闭nginx等代理的缓冲
+	header.Set("X-Accel-Buffering", "no")
+	header.Del("Content-Length")
+	// 写出时SetResponseHeader设置的值会覆盖同名响应头，不能覆盖事件流的类型
+	delete(c.header, CONTENT_TYPE)
+
+	s := &SSEStream{
+		response:    writer,
+		controller:  http.NewResponseController(writer),
+		reqCtx:      c.request.Context(),
+		lastEventID: c.request.Header.Get("Last-Event-ID"),
+		keepAlive:   make(chan time.Duration, 1),
+	}
+	s.ctx, s.cancel = context.WithCancel(s.reqCtx)
+	// 事件流是长连接，不受服务端写超时限制
+	s.controller.SetWriteDeadline(time.Time{})
+	c.sse = s
+
+	writer.WriteHeader(http.StatusOK)
+	s.flush()
+
+	go s.keepAliveLoop(DefaultSSEKeepAlive)
+	return s
+}
+
+// 客户端重连时携带的Last-Event-ID，首次连接为空
+func (s *SSEStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// 客户端断开或事件流关闭时关闭
+func (s *SSEStream) Done() <-chan struct{} {
+	return s.ctx.Done()
+}
+
+// 发送事件，event、id为空时不发送对应字段，多行data按行拆分
+func (s *SSEStream) Send(event string, id string, data string) error {
+	var builder strings.Builder
+	if id != "" {
+		builder.WriteString("id: " + sanitizeField(id) + "\n")
+	}
+	if event != "" {
+		builder.WriteString("event: " + sanitizeField(event) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(data, "\r\n", "\n"), "\n") {
+		builder.WriteString("data: " + line + "\n")
+	}
+	builder.WriteString("\n")
+	return s.write(builder.String())
+}
+
+// 只发送data字段
+func (s *SSEStream) Data(data string) error {
+	return s.Send("", "", data)
+}
+
+// 设置客户端断线重连的等待时间
+func (s *SSEStream) Retry(interval time.Duration) error {
+	return s.write(fmt.Sprintf("retry: %d\n\n", interval.Milliseconds()))
+}
+
+// 发送注释，客户端会忽略，可用作心跳
+func (s *SSEStream) Comment(text string) error {
+	return s.write(": " + sanitizeField(text) + "\n\n")
+}
+
+// 修改心跳间隔，小于等于0时关闭心跳
+func (s *SSEStream) KeepAlive(interval time.Duration) {
+	select {
+	case <-s.keepAlive:
+	default:
+	}
+	s.keepAlive <- interval
+}
+
+// 关闭事件流，停止心跳，之后的发送返回错误
+// 处理函数返回时会自动调用
+func (s *SSEStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.cancel()
+}
+
+func (s *SSEStream) write(message string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return SSE_CLOSED
+	}
+	if err := s.reqCtx.Err(); err != nil {
+		return err
+	}
+	if s.err != nil {
+		return s.err
+	}
+	if _, err := s.response.Write([]byte(message)); err != nil {
+		s.err = err
+		return err
+	}
+	s.flush()
+	return nil
+}
+
+func (s *SSEStream) flush() {
+	if err := s.controller.Flush(); err != nil && s.err == nil && !errors.Is(err, http.ErrNotSupported) {
+		s.err = err
+	}
+}
+
+func (s *SSEStream) keepAliveLoop(interval time.Duration) {
+	timer := time.NewTimer(interval)
+	if interval <= 0 {
+		timer.Stop()
+	}
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case interval = <-s.keepAlive:
+			timer.Stop()
+			if interval > 0 {
+				timer.Reset(interval)
+			}
+		case <-timer.C:
+			if s.Comment("keep-alive") != nil {
+				return
+			}
+			timer.Reset(interval)
+		}
+	}
+}
+
+// 字段值不能包含换行
+func sanitizeField(value string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(value)
+}
@@ -0,0 +1,153 @@
+package wcontext
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+type stubResolver struct{}
+
+func (stubResolver) Resolve(ctx *Context, key reflect.Type) (any, error) {
+	return nil, nil
+}
+
+func TestReleaseContextResets(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/users/1?page=2", nil)
+	ctx := AcquireContext(httptest.NewRecorder(), request)
+
+	ctx.Params["id"] = "1"
+	ctx.Set("user", "weber")
+	ctx.SetResponseHeader("X-Test", "1")
+	ctx.SetStatusCode(http.StatusCreated)
+	ctx.SetResponseBody([]byte("body"))
+	ctx.SetRoutePattern("/users/:id")
+	ctx.SetServices(stubResolver{})
+	ctx.SetLogger(slog.Default())
+	ctx.SetRequestID("req-1")
+	ctx.Logger()
+
+	ReleaseContext(ctx)
+
+	if len(ctx.Params) != 0 {
+		t.Errorf("params = %v", ctx.Params)
+	}
+	if len(ctx.values) != 0 {
+		t.Errorf("values = %v", ctx.values)
+	}
+	if len(ctx.header) != 0 {
+		t.Errorf("header = %v", ctx.header)
+	}
+	if ctx.services != nil {
+		t.Errorf("services = %v", ctx.services)
+	}
+	if ctx.logger != nil || ctx.requestLogger != nil {
+		t.Error("logger not cleared")
+	}
+	if ctx.requestID != "" {
+		t.Errorf("requestID = %q", ctx.requestID)
+	}
+	if ctx.request != nil || ctx.status != 0 || ctx.data != nil || ctx.routePattern != "" || ctx.cacheQuery != nil {
+		t.Error("request state not cleared")
+	}
+	if ctx.response.ResponseWriter != nil || ctx.response.Written() {
+		t.Error("response writer not cleared")
+	}
+
+	// 复用的上下文不能带有上一个请求的数据
+	next := AcquireContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	defer ReleaseContext(next)
+	if _, ok := next.Get("user"); ok || next.RequestID() != "" || len(next.Params) != 0 {
+		t.Error("acquired context carries data of a previous request")
+	}
+}
+
+// 不分配内存的ResponseWriter，避免httptest.ResponseRecorder的分配干扰结果
+type discardWriter struct {
+	header http.Header
+}
+
+func (w *discardWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *discardWriter) Write(data []byte) (int, error) {
+	return len(data), nil
+}
+
+func (w *discardWriter) WriteHeader(code int) {}
+
+// 每个请求新建上下文（baseline）与从对象池获取上下文（pooled）的对比
+//
+//	go test ./wcontext -run ^$ -bench ServeHTTP -benchmem
+func BenchmarkServeHTTP(b *testing.B) {
+	request := httptest.NewRequest(http.MethodGet, "/users/1?page=2&size=20", nil)
+	handle := func(ctx *Context) {
+		ctx.Params["id"] = "1"
+		ctx.SetResponseHeader("X-Request-ID", "req-1")
+		ctx.TEXT("hello")
+		ctx.Complete()
+	}
+
+	serves := map[string]http.HandlerFunc{
+		"baseline": func(w http.ResponseWriter, r *http.Request) {
+			handle(NewContext(w, r))
+		},
+		"pooled": func(w http.ResponseWriter, r *http.Request) {
+			ctx := AcquireContext(w, r)
+			defer ReleaseContext(ctx)
+			handle(ctx)
+		},
+	}
+	for _, name := range []string{"baseline", "pooled"} {
+		serve := serves[name]
+		b.Run(name, func(b *testing.B) {
+			writer := &discardWriter{header: http.Header{}}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				serve(writer, request)
+				for key := range writer.header {
+					delete(writer.header, key)
+				}
+			}
+		})
+	}
+}
+
+func TestWriteHeaderInformational(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	ctx := AcquireContext(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+	defer ReleaseContext(ctx)
+
+	ctx.Writer().Header().Set("Link", "</app.css>; rel=preload")
+	ctx.Writer().WriteHeader(http.StatusEarlyHints)
+	if ctx.response.Written() {
+		t.Fatal("103 should not be treated as the final response")
+	}
+
+	ctx.TEXT("created")
+	ctx.SetStatusCode(http.StatusCreated)
+	ctx.Complete()
+	if ctx.response.Status() != http.StatusCreated || recorder.Body.String() != "created" {
+		t.Errorf("response = %d %q, want %d %q", ctx.response.Status(), recorder.Body.String(), http.StatusCreated, "created")
+	}
+}
+
+func TestResponseStatus(t *testing.T) {
+	ctx := AcquireContext(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	defer ReleaseContext(ctx)
+
+	if status := ctx.ResponseStatus(); status != http.StatusOK {
+		t.Errorf("unset status = %d, want 200", status)
+	}
+	ctx.SetStatusCode(http.StatusNotFound)
+	if status := ctx.ResponseStatus(); status != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", status)
+	}
+	ctx.Writer().WriteHeader(http.StatusAccepted)
+	if status := ctx.ResponseStatus(); status != http.StatusAccepted {
+		t.Errorf("written status = %d, want 202", status)
+	}
+}
@@ -0,0 +1,46 @@
+package wcontext
+
+import (
+	"context"
+	"net/http"
+)
+
+type contextKey struct{}
+
+// 在请求处理过程中保存数据，供后续的中间件和处理函数使用
+func (c *Context) Set(key string, value any) {
+	if c.values == nil {
+		c.values = make(map[string]any)
+	}
+	c.values[key] = value
+}
+
+func (c *Context) Get(key string) (any, bool) {
+	value, ok := c.values[key]
+	return value, ok
+}
+
+// 获取字符串类型的值，不存在或类型不符时返回空字符串
+func (c *Context) GetString(key string) string {
+	value, _ := c.values[key].(string)
+	return value
+}
+
+// 替换当前请求，例如net/http中间件通过r.WithContext传递了新的值
+func (c *Context) SetRequest(r *http.Request) {
+	c.request = r
+}
+
+// 返回携带当前上下文的请求，net/http的处理函数可以通过FromRequest取回上下文
+func (c *Context) BindRequest() *http.Request {
+	if FromRequest(c.request) == c {
+		return c.request
+	}
+	return c.request.WithContext(context.WithValue(c.request.Context(), contextKey{}, c))
+}
+
+// 取回BindRequest绑定的上下文，不存在时返回nil
+func FromRequest(r *http.Request) *Context {
+	ctx, _ := r.Context().Value(contextKey{}).(*Context)
+	return ctx
+}
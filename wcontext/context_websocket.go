@@ -0,0 +1,27 @@
+package wcontext
+
+import (
+	"errors"
+
+	"github.com/asxlwsl/weber/websocket"
+)
+
+// 将当前请求升级为WebSocket连接
+// 握手失败时写入对应的错误响应；通过ctx.Writer()接管连接，升级成功后连接由调用方负责关闭，缓冲的响应不再写出
+func (c *Context) Upgrade(opts *websocket.Options) (*websocket.Conn, error) {
+	conn, err := websocket.Upgrade(c.Writer(), c.request, opts)
+	if err != nil {
+		var handshakeErr *websocket.HandshakeError
+		if errors.As(err, &handshakeErr) {
+			c.Fail(handshakeErr.Status, handshakeErr.Message)
+		}
+		c.Error = err
+		return nil, err
+	}
+	return conn, nil
+}
+
+// 连接是否已被接管
+func (c *Context) Hijacked() bool {
+	return c.response.hijacked
+}
@@ -1,6 +1,14 @@
 package wcontext
 
-import "net/http"
+import (
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// 静态资源路由中通配参数的名称，例如 /assets/*filepath
+const STATIC_PARAM = "filepath"
 
 func HandleErrorReturn(errCode int, errMsg string) HandleFunc {
 	return func(ctx *Context) {
@@ -16,9 +24,33 @@ func HandleNotFound() HandleFunc {
 }
 
 /*处理静态资源*/
-func HandleStaticFile() HandleFunc {
+func HandleStaticFile(root string) HandleFunc {
+
+	notFound := HandleNotFound()
 
 	return func(ctx *Context) {
-		
+		// 先按URL路径清理，防止 ../ 访问root之外的文件
+		name := path.Clean("/" + ctx.Params[STATIC_PARAM])
+		file := filepath.Join(root, filepath.FromSlash(name))
+
+		info, err := os.Stat(file)
+		if err == nil && info.IsDir() {
+			file = filepath.Join(file, "index.html")
+			info, err = os.Stat(file)
+		}
+		if err != nil || info.IsDir() {
+			notFound(ctx)
+			return
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			notFound(ctx)
+			return
+		}
+		defer f.Close()
+
+		// 直接写出文件，不在内存中缓冲；同时支持Range和If-Modified-Since
+		http.ServeContent(ctx.Writer(), ctx.Request(), info.Name(), info.ModTime(), f)
 	}
 }
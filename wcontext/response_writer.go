@@ -0,0 +1,131 @@
+package wcontext
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+)
+
+// 直接写出的响应，记录写出的状态码、字节数以及响应头是否已发送
+// 通过ctx.Writer()获取，开始写出后缓冲的响应不再写出
+type ResponseWriter interface {
+	http.ResponseWriter
+	http.Flusher
+	http.Hijacker
+	io.ReaderFrom
+
+	// 已发送的状态码，尚未发送时为0
+	Status() int
+
+	// 已写出的响应体字节数
+	Size() int64
+
+	// 响应头是否已发送（或连接已被接管）
+	Written() bool
+
+	// 原始的ResponseWriter，供http.ResponseController使用
+	Unwrap() http.ResponseWriter
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+
+	ctx *Context
+
+	status      int
+	size        int64
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *responseWriter) reset(rw http.ResponseWriter) {
+	w.ResponseWriter = rw
+	w.status = 0
+	w.size = 0
+	w.wroteHeader = false
+	w.hijacked = false
+}
+
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+func (w *responseWriter) Size() int64 {
+	return w.size
+}
+
+func (w *responseWriter) Written() bool {
+	return w.wroteHeader || w.hijacked
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// 发送响应头，SetResponseHeader设置的响应头一并写出，重复调用无效
+// 1xx的中间响应（如103 Early Hints）直接透传，之后仍可发送最终的响应头
+func (w *responseWriter) WriteHeader(code int) {
+	if w.Written() {
+		return
+	}
+	if code >= 100 && code < 200 && code != http.StatusSwitchingProtocols {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	for key, value := range w.ctx.header {
+		w.ResponseWriter.Header().Set(key, value)
+	}
+	w.status = code
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// 尚未发送响应头时，使用ctx上设置的状态码
+func (w *responseWriter) writeHeaderNow() {
+	if w.Written() {
+		return
+	}
+	w.WriteHeader(w.ctx.ResponseStatus())
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.writeHeaderNow()
+	n, err := w.ResponseWriter.Write(data)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.writeHeaderNow()
+	n, err := io.WriteString(w.ResponseWriter, s)
+	w.size += int64(n)
+	return n, err
+}
+
+// 使用底层的ReaderFrom（如sendfile）写出
+func (w *responseWriter) ReadFrom(r io.Reader) (int64, error) {
+	w.writeHeaderNow()
+	n, err := io.Copy(w.ResponseWriter, r)
+	w.size += n
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	w.FlushError()
+}
+
+// 供http.ResponseController使用，返回底层Flush的错误
+func (w *responseWriter) FlushError() error {
+	w.writeHeaderNow()
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// 接管连接，之后不能再通过ResponseWriter写出
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, brw, err
+}
@@ -0,0 +1,109 @@
+// weber 提供依赖注入等需要泛型的便捷函数和模块的别名，服务本身见server包
+//
+//	srv := server.NewHttpServer()
+//	weber.Provide(srv, func(c *server.Container) (*sql.DB, error) {
+//		return sql.Open("mysql", dsn)
+//	})
+//	weber.ProvideRequest(srv, func(ctx *wcontext.Context) (*UserRepo, error) {
+//		db, err := weber.Resolve[*sql.DB](ctx)
+//		return &UserRepo{db: db}, err
+//	})
+//	srv.GET("/user/:id", func(ctx *wcontext.Context) {
+//		repo := weber.MustResolve[*UserRepo](ctx)
+//		...
+//	})
+package weber
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/asxlwsl/weber/server"
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+// 模块，见server.Module
+type Module = server.Module
+
+type ModuleDependencies = server.ModuleDependencies
+type ModulePrefix = server.ModulePrefix
+type ModuleStarter = server.ModuleStarter
+type ModuleStopper = server.ModuleStopper
+
+// 为模块指定路由前缀
+func Mount(prefix string, module Module) Module {
+	return server.Mount(prefix, module)
+}
+
+var (
+	NO_CONTAINER = errors.New("no service container on context")
+)
+
+// 服务的类型，接口类型也可以作为键
+func typeOf[T any]() reflect.Type {
+	return reflect.TypeOf((*T)(nil)).Elem()
+}
+
+// 注册单例服务，第一次获取时创建，服务关闭时调用其Close
+// 工厂函数通过Get获取其他单例服务
+func Provide[T any](srv *server.HttpServer, factory func(c *server.Container) (T, error)) {
+	srv.Services().Provide(typeOf[T](), server.Singleton, func(c *server.Container, ctx *wcontext.Context) (any, error) {
+		return factory(c)
+	})
+}
+
+// 注册单例服务的已有实例
+func ProvideValue[T any](srv *server.HttpServer, value T) {
+	Provide(srv, func(c *server.Container) (T, error) {
+		return value, nil
+	})
+}
+
+// 注册请求级服务，每个请求第一次获取时创建，请求结束时调用其Close
+// 工厂函数通过Resolve(ctx)获取其他服务
+func ProvideRequest[T any](srv *server.HttpServer, factory func(ctx *wcontext.Context) (T, error)) {
+	srv.Services().Provide(typeOf[T](), server.RequestScope, func(c *server.Container, ctx *wcontext.Context) (any, error) {
+		return factory(ctx)
+	})
+}
+
+// 在处理函数中获取服务
+// ctx.Copy()得到的副本不携带服务容器
+func Resolve[T any](ctx *wcontext.Context) (T, error) {
+	var zero T
+	services := ctx.Services()
+	if services == nil {
+		return zero, NO_CONTAINER
+	}
+	return cast[T](services.Resolve(ctx, typeOf[T]()))
+}
+
+// 获取服务，失败时panic（由错误恢复中间件处理）
+func MustResolve[T any](ctx *wcontext.Context) T {
+	value, err := Resolve[T](ctx)
+	if err != nil {
+		panic(err)
+	}
+	return value
+}
+
+// 在请求之外（如单例的工厂函数、启动钩子中）获取单例服务
+func Get[T any](c *server.Container) (T, error) {
+	return cast[T](c.Resolve(nil, typeOf[T]()))
+}
+
+func cast[T any](value any, err error) (T, error) {
+	var zero T
+	if err != nil {
+		return zero, err
+	}
+	if value == nil {
+		return zero, nil
+	}
+	typed, ok := value.(T)
+	if !ok {
+		return zero, fmt.Errorf("service %v has unexpected type %T", typeOf[T](), value)
+	}
+	return typed, nil
+}
@@ -0,0 +1,59 @@
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+	"sync"
+)
+
+// permessage-deflate（无上下文复用）：每条消息独立压缩
+
+// 压缩数据末尾的同步标记，发送时去掉，接收时补上
+var deflateTail = []byte{0x00, 0x00, 0xff, 0xff}
+
+// 补上同步标记后再追加一个空的最终块，避免flate读取时报unexpected EOF
+var inflateTail = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+var errTooBig = errors.New("websocket: decompressed message too big")
+
+var flateWriterPool = sync.Pool{
+	New: func() any {
+		writer, _ := flate.NewWriter(nil, flate.BestSpeed)
+		return writer
+	},
+}
+
+func compress(data []byte) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	writer := flateWriterPool.Get().(*flate.Writer)
+	defer flateWriterPool.Put(writer)
+	writer.Reset(buf)
+
+	if _, err := writer.Write(data); err != nil {
+		return nil, err
+	}
+	if err := writer.Flush(); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), deflateTail), nil
+}
+
+// limit大于0时限制解压后的大小
+func decompress(data []byte, limit int64) ([]byte, error) {
+	reader := flate.NewReader(io.MultiReader(bytes.NewReader(data), bytes.NewReader(inflateTail)))
+	defer reader.Close()
+
+	if limit <= 0 {
+		return io.ReadAll(reader)
+	}
+	message, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) > limit {
+		return nil, errTooBig
+	}
+	return message, nil
+}
@@ -0,0 +1,456 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// 消息类型（帧操作码）
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+// 关闭码
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+)
+
+const (
+	finalBit = 0x80
+	rsv1Bit  = 0x40
+	rsv2Bit  = 0x20
+	rsv3Bit  = 0x10
+	maskBit  = 0x80
+
+	// 控制帧的最大负载
+	maxControlPayload = 125
+
+	// 发送关闭帧后等待写出的时间
+	closeWriteTimeout = 5 * time.Second
+)
+
+var (
+	CONN_CLOSED = errors.New("websocket: connection closed")
+)
+
+// 对端发送的关闭帧，或因协议错误而关闭
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// WebSocket连接
+// 同一时间只能有一个协程读取，写入是并发安全的
+type Conn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	writer  *bufio.Writer
+
+	subprotocol string
+
+	// 是否协商了permessage-deflate
+	compress bool
+
+	readLimit         int64
+	writeFragmentSize int
+
+	pingHandler func(data string) error
+	pongHandler func(data string) error
+
+	// 已发送关闭帧
+	closeSent bool
+
+	closeOnce sync.Once
+}
+
+func newConn(conn net.Conn, reader *bufio.Reader, writer *bufio.Writer, subprotocol string, compress bool, readLimit int64, writeFragmentSize int) *Conn {
+	c := &Conn{
+		conn:              conn,
+		reader:            reader,
+		writer:            writer,
+		subprotocol:       subprotocol,
+		compress:          compress,
+		readLimit:         readLimit,
+		writeFragmentSize: writeFragmentSize,
+	}
+	c.pingHandler = func(data string) error {
+		return c.WriteControl(PongMessage, []byte(data))
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+// 协商的子协议
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// 是否启用了压缩
+func (c *Conn) Compressed() bool {
+	return c.compress
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// 设置单条消息的最大字节数，负数表示不限制
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// 收到ping时执行，默认回复pong
+func (c *Conn) SetPingHandler(handler func(data string) error) {
+	c.pingHandler = handler
+}
+
+// 收到pong时执行
+func (c *Conn) SetPongHandler(handler func(data string) error) {
+	c.pongHandler = handler
+}
+
+// 读取一条完整的消息，自动处理控制帧和分片
+// 对端关闭连接时返回*CloseError
+func (c *Conn) ReadMessage() (messageType int, data []byte, err error) {
+	var message []byte
+	compressed := false
+
+	for {
+		fin, rsv1, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case PingMessage:
+			if err := c.pingHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if err := c.pongHandler(string(payload)); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case CloseMessage:
+			return 0, nil, c.handleClose(payload)
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "new message before previous message finished")
+			}
+			messageType = opcode
+			compressed = rsv1
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "continuation frame without message")
+			}
+			if rsv1 {
+				return 0, nil, c.fail(CloseProtocolError, "RSV1 set on continuation frame")
+			}
+		}
+
+		if c.readLimit > 0 && int64(len(message)+len(payload)) > c.readLimit {
+			return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+		}
+		message = append(message, payload...)
+
+		if !fin {
+			continue
+		}
+
+		if compressed {
+			if message, err = decompress(message, c.readLimit); err != nil {
+				if errors.Is(err, errTooBig) {
+					return 0, nil, c.fail(CloseMessageTooBig, "message too big")
+				}
+				return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid compressed data")
+			}
+		}
+		if messageType == TextMessage && !utf8.Valid(message) {
+			return 0, nil, c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in text message")
+		}
+		return messageType, message, nil
+	}
+}
+
+// 读取一帧
+func (c *Conn) readFrame() (fin bool, rsv1 bool, opcode int, payload []byte, err error) {
+	var header [2]byte
+	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+		return false, false, 0, nil, err
+	}
+
+	fin = header[0]&finalBit != 0
+	rsv1 = header[0]&rsv1Bit != 0
+	opcode = int(header[0] & 0x0f)
+	masked := header[1]&maskBit != 0
+	length := int64(header[1] & 0x7f)
+
+	if header[0]&(rsv2Bit|rsv3Bit) != 0 || (rsv1 && !c.compress) {
+		return false, false, 0, nil, c.fail(CloseProtocolError, "unexpected reserved bits")
+	}
+	switch opcode {
+	case continuationFrame, TextMessage, BinaryMessage:
+	case CloseMessage, PingMessage, PongMessage:
+		if !fin || length > maxControlPayload {
+			return false, false, 0, nil, c.fail(CloseProtocolError, "invalid control frame")
+		}
+		if rsv1 {
+			return false, false, 0, nil, c.fail(CloseProtocolError, "RSV1 set on control frame")
+		}
+	default:
+		return false, false, 0, nil, c.fail(CloseProtocolError, fmt.Sprintf("unknown opcode %d", opcode))
+	}
+	// 客户端发送的帧必须加掩码
+	if !masked {
+		return false, false, 0, nil, c.fail(CloseProtocolError, "frame is not masked")
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.reader, ext[:]); err != nil {
+			return false, false, 0, nil, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+		if length < 0 {
+			return false, false, 0, nil, c.fail(CloseProtocolError, "invalid frame length")
+		}
+	}
+	if c.readLimit > 0 && length > c.readLimit {
+		return false, false, 0, nil, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.reader, mask[:]); err != nil {
+		return false, false, 0, nil, err
+	}
+
+	payload = make([]byte, length)
+	if _, err := io.ReadFull(c.reader, payload); err != nil {
+		return false, false, 0, nil, err
+	}
+	for idx := range payload {
+		payload[idx] ^= mask[idx%4]
+	}
+	return fin, rsv1, opcode, payload, nil
+}
+
+// 处理对端的关闭帧：校验后回复同样的关闭码
+func (c *Conn) handleClose(payload []byte) error {
+	code := CloseNoStatusReceived
+	text := ""
+	switch {
+	case len(payload) == 1:
+		return c.fail(CloseProtocolError, "invalid close payload")
+	case len(payload) >= 2:
+		code = int(binary.BigEndian.Uint16(payload))
+		text = string(payload[2:])
+		if !validCloseCode(code) {
+			return c.fail(CloseProtocolError, "invalid close code")
+		}
+		if !utf8.ValidString(text) {
+			return c.fail(CloseInvalidFramePayloadData, "invalid UTF-8 in close reason")
+		}
+	}
+
+	var reply []byte
+	if code != CloseNoStatusReceived {
+		reply = closePayload(code, "")
+	}
+	c.writeClose(reply)
+	return &CloseError{Code: code, Text: text}
+}
+
+// 因错误关闭连接
+func (c *Conn) fail(code int, text string) error {
+	c.writeClose(closePayload(code, text))
+	c.conn.Close()
+	return &CloseError{Code: code, Text: text}
+}
+
+func (c *Conn) writeClose(payload []byte) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return
+	}
+	c.closeSent = true
+	c.conn.SetWriteDeadline(time.Now().Add(closeWriteTimeout))
+	c.writeFrame(true, false, CloseMessage, payload)
+}
+
+func validCloseCode(code int) bool {
+	switch {
+	case code >= 1000 && code <= 1003, code >= 1007 && code <= 1011, code >= 3000 && code <= 4999:
+		return true
+	}
+	return false
+}
+
+func closePayload(code int, text string) []byte {
+	payload := make([]byte, 2, 2+len(text))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	return append(payload, text...)
+}
+
+// 发送一条消息，启用压缩时自动压缩，设置了分片大小时自动分片
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	if messageType != TextMessage && messageType != BinaryMessage {
+		return c.WriteControl(messageType, data)
+	}
+
+	compressed := false
+	if c.compress {
+		var err error
+		if data, err = compress(data); err != nil {
+			return err
+		}
+		compressed = true
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return CONN_CLOSED
+	}
+
+	opcode := messageType
+	for first := true; first || len(data) > 0; first = false {
+		chunk := data
+		if c.writeFragmentSize > 0 && len(chunk) > c.writeFragmentSize {
+			chunk = chunk[:c.writeFragmentSize]
+		}
+		data = data[len(chunk):]
+
+		// 压缩标志只设置在第一个分片上
+		if err := c.writeFrame(len(data) == 0, compressed && first, opcode, chunk); err != nil {
+			return err
+		}
+		opcode = continuationFrame
+	}
+	return nil
+}
+
+func (c *Conn) WriteText(text string) error {
+	return c.WriteMessage(TextMessage, []byte(text))
+}
+
+func (c *Conn) WriteBinary(data []byte) error {
+	return c.WriteMessage(BinaryMessage, data)
+}
+
+// 发送控制帧（ping、pong、close）
+func (c *Conn) WriteControl(messageType int, data []byte) error {
+	if messageType != PingMessage && messageType != PongMessage && messageType != CloseMessage {
+		return fmt.Errorf("websocket: invalid control message type %d", messageType)
+	}
+	if len(data) > maxControlPayload {
+		return fmt.Errorf("websocket: control frame payload too large")
+	}
+	if messageType == CloseMessage {
+		c.writeClose(data)
+		return nil
+	}
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return CONN_CLOSED
+	}
+	return c.writeFrame(true, false, messageType, data)
+}
+
+func (c *Conn) Ping(data []byte) error {
+	return c.WriteControl(PingMessage, data)
+}
+
+// 写入一帧，调用方持有writeMu；服务端发送的帧不加掩码
+func (c *Conn) writeFrame(fin bool, rsv1 bool, opcode int, payload []byte) error {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+
+	header := []byte{b0, 0}
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	if _, err := c.writer.Write(header); err != nil {
+		return err
+	}
+	if _, err := c.writer.Write(payload); err != nil {
+		return err
+	}
+	return c.writer.Flush()
+}
+
+// 以code关闭连接
+func (c *Conn) CloseWithCode(code int, text string) error {
+	c.writeClose(closePayload(code, text))
+	return c.Close()
+}
+
+// 关闭底层连接，尚未发送关闭帧时先发送1000
+func (c *Conn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		c.writeClose(closePayload(CloseNormalClosure, ""))
+		err = c.conn.Close()
+	})
+	return err
+}
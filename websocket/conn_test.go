@@ -0,0 +1,397 @@
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+type frame struct {
+	fin     bool
+	rsv1    bool
+	opcode  int
+	payload []byte
+}
+
+// 模拟客户端：发送加掩码的帧，读取服务端发送的帧
+type peer struct {
+	t      *testing.T
+	conn   net.Conn
+	frames chan frame
+}
+
+func newPair(t *testing.T, compress bool, readLimit int64) (*Conn, *peer) {
+	server, client := net.Pipe()
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	c := newConn(server, bufio.NewReader(server), bufio.NewWriter(server), "", compress, readLimit, 0)
+	p := &peer{t: t, conn: client, frames: make(chan frame, 16)}
+	go p.readLoop(bufio.NewReader(client))
+	return c, p
+}
+
+func (p *peer) readLoop(reader *bufio.Reader) {
+	defer close(p.frames)
+	for {
+		f, err := readServerFrame(reader)
+		if err != nil {
+			return
+		}
+		p.frames <- f
+	}
+}
+
+// 服务端发送的帧不加掩码
+func readServerFrame(reader io.Reader) (frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(reader, header[:]); err != nil {
+		return frame{}, err
+	}
+	length := int64(header[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(reader, ext[:]); err != nil {
+			return frame{}, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return frame{}, err
+	}
+	return frame{
+		fin:     header[0]&finalBit != 0,
+		rsv1:    header[0]&rsv1Bit != 0,
+		opcode:  int(header[0] & 0x0f),
+		payload: payload,
+	}, nil
+}
+
+// 客户端的帧，masked为false时不加掩码（违反协议）
+func clientFrame(fin bool, rsv1 bool, opcode int, payload []byte, masked bool) []byte {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= finalBit
+	}
+	if rsv1 {
+		b0 |= rsv1Bit
+	}
+	b1 := byte(0)
+	if masked {
+		b1 = maskBit
+	}
+
+	data := []byte{b0, b1}
+	switch length := len(payload); {
+	case length <= 125:
+		data[1] |= byte(length)
+	case length <= 0xffff:
+		data[1] |= 126
+		data = binary.BigEndian.AppendUint16(data, uint16(length))
+	default:
+		data[1] |= 127
+		data = binary.BigEndian.AppendUint64(data, uint64(length))
+	}
+	if !masked {
+		return append(data, payload...)
+	}
+	mask := [4]byte{0x12, 0x34, 0x56, 0x78}
+	data = append(data, mask[:]...)
+	for idx, b := range payload {
+		data = append(data, b^mask[idx%4])
+	}
+	return data
+}
+
+func text(fin bool, payload string) []byte {
+	return clientFrame(fin, false, TextMessage, []byte(payload), true)
+}
+
+func control(opcode int, payload []byte) []byte {
+	return clientFrame(true, false, opcode, payload, true)
+}
+
+func continuation(fin bool, payload string) []byte {
+	return clientFrame(fin, false, continuationFrame, []byte(payload), true)
+}
+
+// net.Pipe的写入会阻塞到服务端读取，在单独的协程中按顺序写出
+func (p *peer) send(frames ...[]byte) {
+	go p.conn.Write(bytes.Join(frames, nil))
+}
+
+func (p *peer) expect(opcode int) frame {
+	p.t.Helper()
+	select {
+	case f, ok := <-p.frames:
+		if !ok {
+			p.t.Fatalf("connection closed, expected opcode %d", opcode)
+		}
+		if f.opcode != opcode {
+			p.t.Fatalf("opcode = %d, want %d", f.opcode, opcode)
+		}
+		return f
+	case <-time.After(time.Second):
+		p.t.Fatalf("timeout waiting for opcode %d", opcode)
+	}
+	return frame{}
+}
+
+// 读取消息应失败并以code关闭，客户端收到同样关闭码的关闭帧
+func expectClose(t *testing.T, c *Conn, p *peer, code int) {
+	t.Helper()
+	_, _, err := c.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != code {
+		t.Fatalf("err = %v, want close %d", err, code)
+	}
+	f := p.expect(CloseMessage)
+	if got := int(binary.BigEndian.Uint16(f.payload)); got != code {
+		t.Fatalf("close frame code = %d, want %d", got, code)
+	}
+}
+
+func TestUnmaskedFrameRejected(t *testing.T) {
+	c, p := newPair(t, false, 0)
+	p.send(clientFrame(true, false, TextMessage, []byte("hello"), false))
+	expectClose(t, c, p, CloseProtocolError)
+}
+
+func TestReservedBitsRejected(t *testing.T) {
+	c, p := newPair(t, false, 0)
+	p.send([]byte{finalBit | rsv2Bit | TextMessage, maskBit})
+	expectClose(t, c, p, CloseProtocolError)
+
+	// 未协商压缩时不能设置RSV1
+	c, p = newPair(t, false, 0)
+	p.send(clientFrame(true, true, TextMessage, []byte("hello"), true))
+	expectClose(t, c, p, CloseProtocolError)
+}
+
+func TestFragmentedMessage(t *testing.T) {
+	c, p := newPair(t, false, 0)
+	p.send(
+		text(false, "Hel"),
+		control(PingMessage, []byte("p1")),
+		continuation(false, "lo "),
+		control(PongMessage, []byte("ignored")),
+		continuation(true, "World"),
+	)
+
+	messageType, data, err := c.ReadMessage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if messageType != TextMessage || string(data) != "Hello World" {
+		t.Fatalf("message = %d %q", messageType, data)
+	}
+	// 分片之间的ping立即回复
+	if pong := p.expect(PongMessage); string(pong.payload) != "p1" {
+		t.Fatalf("pong = %q", pong.payload)
+	}
+}
+
+func TestCloseDuringFragmentedMessage(t *testing.T) {
+	c, p := newPair(t, false, 0)
+	p.send(text(false, "partial"), control(CloseMessage, closePayload(CloseGoingAway, "bye")))
+
+	_, _, err := c.ReadMessage()
+	var closeErr *CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != CloseGoingAway || closeErr.Text != "bye" {
+		t.Fatalf("err = %v", err)
+	}
+	f := p.expect(CloseMessage)
+	if code := binary.BigEndian.Uint16(f.payload); code != CloseGoingAway {
+		t.Fatalf("reply code = %d", code)
+	}
+	// 已回复关闭帧，之后不能再发送消息
+	if err := c.WriteText("late"); !errors.Is(err, CONN_CLOSED) {
+		t.Fatalf("write after close = %v", err)
+	}
+}
+
+func TestFragmentationErrors(t *testing.T) {
+	cases := map[string][][]byte{
+		"continuation without message": {continuation(true, "x")},
+		"new message before finished":  {text(false, "a"), text(true, "b")},
+		"fragmented control frame":     {clientFrame(false, false, PingMessage, nil, true)},
+		"control frame too large":      {control(PingMessage, make([]byte, maxControlPayload+1))},
+		"unknown opcode":               {clientFrame(true, false, 3, nil, true)},
+	}
+	for name, frames := range cases {
+		t.Run(name, func(t *testing.T) {
+			c, p := newPair(t, false, 0)
+			p.send(frames...)
+			expectClose(t, c, p, CloseProtocolError)
+		})
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	t.Run("single frame", func(t *testing.T) {
+		c, p := newPair(t, false, 10)
+		p.send(text(true, strings.Repeat("a", 11)))
+		expectClose(t, c, p, CloseMessageTooBig)
+	})
+	t.Run("fragments", func(t *testing.T) {
+		c, p := newPair(t, false, 10)
+		p.send(text(false, "aaaaaa"), continuation(true, "aaaaaa"))
+		expectClose(t, c, p, CloseMessageTooBig)
+	})
+	t.Run("decompressed", func(t *testing.T) {
+		c, p := newPair(t, true, 10)
+		payload, _ := compress([]byte(strings.Repeat("a", 100)))
+		p.send(clientFrame(true, true, TextMessage, payload, true))
+		expectClose(t, c, p, CloseMessageTooBig)
+	})
+	t.Run("within limit", func(t *testing.T) {
+		c, p := newPair(t, false, 10)
+		p.send(text(false, "aaaaa"), continuation(true, "aaaaa"))
+		if _, data, err := c.ReadMessage(); err != nil || len(data) != 10 {
+			t.Fatalf("data = %q, err = %v", data, err)
+		}
+	})
+}
+
+func TestCloseCodes(t *testing.T) {
+	for _, code := range []int{999, 1004, 1005, 1006, 1012, 1015, 1016, 2999, 5000} {
+		c, p := newPair(t, false, 0)
+		p.send(control(CloseMessage, closePayload(code, "")))
+		expectClose(t, c, p, CloseProtocolError)
+	}
+
+	for _, code := range []int{1000, 1001, 1003, 1007, 1011, 3000, 4999} {
+		c, p := newPair(t, false, 0)
+		p.send(control(CloseMessage, closePayload(code, "reason")))
+		_, _, err := c.ReadMessage()
+		var closeErr *CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != code || closeErr.Text != "reason" {
+			t.Fatalf("code %d: err = %v", code, err)
+		}
+		if f := p.expect(CloseMessage); int(binary.BigEndian.Uint16(f.payload)) != code {
+			t.Fatalf("code %d: reply = %v", code, f.payload)
+		}
+	}
+
+	t.Run("one byte payload", func(t *testing.T) {
+		c, p := newPair(t, false, 0)
+		p.send(control(CloseMessage, []byte{0x03}))
+		expectClose(t, c, p, CloseProtocolError)
+	})
+	t.Run("empty payload", func(t *testing.T) {
+		c, p := newPair(t, false, 0)
+		p.send(control(CloseMessage, nil))
+		_, _, err := c.ReadMessage()
+		var closeErr *CloseError
+		if !errors.As(err, &closeErr) || closeErr.Code != CloseNoStatusReceived {
+			t.Fatalf("err = %v", err)
+		}
+		// 没有关闭码时回复空的关闭帧
+		if f := p.expect(CloseMessage); len(f.payload) != 0 {
+			t.Fatalf("reply = %v", f.payload)
+		}
+	})
+	t.Run("invalid UTF-8 reason", func(t *testing.T) {
+		c, p := newPair(t, false, 0)
+		p.send(control(CloseMessage, closePayload(CloseNormalClosure, "\xff")))
+		expectClose(t, c, p, CloseInvalidFramePayloadData)
+	})
+}
+
+func TestInvalidUTF8(t *testing.T) {
+	c, p := newPair(t, false, 0)
+	p.send(text(true, "bad \xff"))
+	expectClose(t, c, p, CloseInvalidFramePayloadData)
+
+	// 多字节字符被分片拆开时按完整消息校验
+	c, p = newPair(t, false, 0)
+	p.send(text(false, "caf\xc3"), continuation(true, "\xa9"))
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != "café" {
+		t.Fatalf("data = %q, err = %v", data, err)
+	}
+
+	// 二进制消息不校验
+	c, p = newPair(t, false, 0)
+	p.send(clientFrame(true, false, BinaryMessage, []byte{0xff}, true))
+	if messageType, _, err := c.ReadMessage(); err != nil || messageType != BinaryMessage {
+		t.Fatalf("type = %d, err = %v", messageType, err)
+	}
+}
+
+func TestCompressedRoundTrip(t *testing.T) {
+	c, p := newPair(t, true, 0)
+	message := strings.Repeat("hello websocket ", 20)
+
+	// 客户端发送的压缩消息，RSV1只设置在第一个分片上
+	payload, _ := compress([]byte(message))
+	half := len(payload) / 2
+	p.send(
+		clientFrame(false, true, TextMessage, payload[:half], true),
+		clientFrame(true, false, continuationFrame, payload[half:], true),
+	)
+	if _, data, err := c.ReadMessage(); err != nil || string(data) != message {
+		t.Fatalf("data = %q, err = %v", data, err)
+	}
+
+	// 不复用上下文：同一条消息每次压缩的结果相同，且可以独立解压
+	var sent [][]byte
+	for i := 0; i < 2; i++ {
+		go c.WriteText(message)
+		f := p.expect(TextMessage)
+		if !f.rsv1 || !f.fin {
+			t.Fatalf("frame rsv1 = %v fin = %v", f.rsv1, f.fin)
+		}
+		data, err := decompress(f.payload, 0)
+		if err != nil || string(data) != message {
+			t.Fatalf("decompressed = %q, err = %v", data, err)
+		}
+		sent = append(sent, f.payload)
+	}
+	if !bytes.Equal(sent[0], sent[1]) {
+		t.Fatal("compressed payloads differ, context was taken over")
+	}
+	if len(sent[0]) >= len(message) {
+		t.Fatalf("payload not compressed: %d >= %d", len(sent[0]), len(message))
+	}
+}
+
+func TestCompressedFragmentedWrite(t *testing.T) {
+	c, p := newPair(t, true, 0)
+	c.writeFragmentSize = 8
+	message := strings.Repeat("abcdefgh", 50) + "tail"
+
+	go c.WriteText(message)
+	var payload []byte
+	for first := true; ; first = false {
+		opcode := continuationFrame
+		if first {
+			opcode = TextMessage
+		}
+		f := p.expect(opcode)
+		if f.rsv1 != first {
+			t.Fatalf("rsv1 = %v on fragment, first = %v", f.rsv1, first)
+		}
+		payload = append(payload, f.payload...)
+		if f.fin {
+			break
+		}
+	}
+	if data, err := decompress(payload, 0); err != nil || string(data) != message {
+		t.Fatalf("data = %q, err = %v", data, err)
+	}
+}
@@ -0,0 +1,200 @@
+// WebSocket（RFC 6455）服务端实现
+// 支持文本/二进制消息、分片、ping/pong、关闭码、消息大小限制和permessage-deflate压缩（RFC 7692）
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// 握手时计算Sec-WebSocket-Accept使用的固定GUID
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	// 默认单条消息最大32MB
+	DefaultReadLimit = 32 << 20
+)
+
+type Options struct {
+	// 单条消息（解压后）的最大字节数，超过时以1009关闭连接，0表示使用默认值，负数表示不限制
+	ReadLimit int64
+
+	// 发送消息时每个分片的最大字节数，0表示不分片
+	WriteFragmentSize int
+
+	// 客户端支持时启用permessage-deflate压缩
+	EnableCompression bool
+
+	// 服务端支持的子协议，按客户端的顺序选择第一个匹配的
+	Subprotocols []string
+
+	// 校验Origin，为空时要求Origin与Host相同（未携带Origin的非浏览器客户端放行）
+	CheckOrigin func(r *http.Request) bool
+}
+
+// 握手失败，调用方应以Status响应客户端
+type HandshakeError struct {
+	Status  int
+	Message string
+}
+
+func (e *HandshakeError) Error() string {
+	return "websocket: " + e.Message
+}
+
+// 校验握手请求，返回的错误为*HandshakeError
+func CheckHandshake(r *http.Request, opts *Options) error {
+	if r.Method != http.MethodGet {
+		return &HandshakeError{Status: http.StatusMethodNotAllowed, Message: "request method is not GET"}
+	}
+	if !headerContainsToken(r.Header, "Connection", "upgrade") {
+		return &HandshakeError{Status: http.StatusBadRequest, Message: "'upgrade' token not found in 'Connection' header"}
+	}
+	if !headerContainsToken(r.Header, "Upgrade", "websocket") {
+		return &HandshakeError{Status: http.StatusBadRequest, Message: "'websocket' token not found in 'Upgrade' header"}
+	}
+	if r.Header.Get("Sec-Websocket-Version") != "13" {
+		return &HandshakeError{Status: http.StatusUpgradeRequired, Message: "unsupported version"}
+	}
+	key := r.Header.Get("Sec-Websocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return &HandshakeError{Status: http.StatusBadRequest, Message: "invalid 'Sec-WebSocket-Key' header"}
+	}
+
+	checkOrigin := sameOrigin
+	if opts != nil && opts.CheckOrigin != nil {
+		checkOrigin = opts.CheckOrigin
+	}
+	if !checkOrigin(r) {
+		return &HandshakeError{Status: http.StatusForbidden, Message: "origin not allowed"}
+	}
+	return nil
+}
+
+// 完成握手并接管连接
+// 握手请求不合法时返回*HandshakeError，此时尚未写出任何数据，由调用方写出错误响应
+func Upgrade(w http.ResponseWriter, r *http.Request, opts *Options) (*Conn, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+	if err := CheckHandshake(r, opts); err != nil {
+		return nil, err
+	}
+
+	subprotocol := selectSubprotocol(r, opts.Subprotocols)
+	compress := opts.EnableCompression && acceptDeflate(r.Header)
+
+	netConn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, &HandshakeError{Status: http.StatusInternalServerError, Message: err.Error()}
+	}
+
+	// net/http设置的读写超时对接管后的长连接不再适用
+	netConn.SetDeadline(time.Time{})
+
+	var response strings.Builder
+	response.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	response.WriteString("Upgrade: websocket\r\n")
+	response.WriteString("Connection: Upgrade\r\n")
+	response.WriteString("Sec-WebSocket-Accept: " + acceptKey(r.Header.Get("Sec-Websocket-Key")) + "\r\n")
+	if subprotocol != "" {
+		response.WriteString("Sec-WebSocket-Protocol: " + subprotocol + "\r\n")
+	}
+	if compress {
+		response.WriteString("Sec-WebSocket-Extensions: permessage-deflate; server_no_context_takeover; client_no_context_takeover\r\n")
+	}
+	response.WriteString("\r\n")
+
+	if _, err := brw.WriteString(response.String()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := brw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	readLimit := opts.ReadLimit
+	if readLimit == 0 {
+		readLimit = DefaultReadLimit
+	}
+	return newConn(netConn, brw.Reader, bufio.NewWriter(netConn), subprotocol, compress, readLimit, opts.WriteFragmentSize), nil
+}
+
+func acceptKey(key string) string {
+	hash := sha1.New()
+	hash.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(hash.Sum(nil))
+}
+
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+func selectSubprotocol(r *http.Request, supported []string) string {
+	for _, value := range r.Header.Values("Sec-Websocket-Protocol") {
+		for _, protocol := range strings.Split(value, ",") {
+			protocol = strings.TrimSpace(protocol)
+			for _, candidate := range supported {
+				if candidate == protocol {
+					return protocol
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// 客户端是否提供了可以接受的permessage-deflate参数
+// 不支持缩小服务端窗口（server_max_window_bits小于15）
+func acceptDeflate(header http.Header) bool {
+	for _, value := range header.Values("Sec-Websocket-Extensions") {
+		for _, offer := range strings.Split(value, ",") {
+			params := strings.Split(offer, ";")
+			if strings.TrimSpace(params[0]) != "permessage-deflate" {
+				continue
+			}
+			acceptable := true
+			for _, param := range params[1:] {
+				name, value, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if name == "server_max_window_bits" && strings.Trim(value, `"`) != "15" {
+					acceptable = false
+				}
+			}
+			if acceptable {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, item := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(item), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 底层连接
+func (c *Conn) NetConn() net.Conn {
+	return c.conn
+}
@@ -0,0 +1,180 @@
+package websocket
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func handshakeRequest() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "http://example.com/ws", nil)
+	r.Header.Set("Connection", "keep-alive, Upgrade")
+	r.Header.Set("Upgrade", "websocket")
+	r.Header.Set("Sec-WebSocket-Version", "13")
+	r.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	return r
+}
+
+func TestCheckHandshake(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(r *http.Request)
+		status int
+	}{
+		{"valid", func(r *http.Request) {}, 0},
+		{"same origin", func(r *http.Request) { r.Header.Set("Origin", "https://example.com") }, 0},
+		{"method", func(r *http.Request) { r.Method = http.MethodPost }, http.StatusMethodNotAllowed},
+		{"connection", func(r *http.Request) { r.Header.Set("Connection", "keep-alive") }, http.StatusBadRequest},
+		{"upgrade", func(r *http.Request) { r.Header.Set("Upgrade", "h2c") }, http.StatusBadRequest},
+		{"version", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Version", "8") }, http.StatusUpgradeRequired},
+		{"key", func(r *http.Request) { r.Header.Set("Sec-WebSocket-Key", "c2hvcnQ=") }, http.StatusBadRequest},
+		{"origin", func(r *http.Request) { r.Header.Set("Origin", "https://evil.com") }, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := handshakeRequest()
+		c.modify(r)
+		err := CheckHandshake(r, nil)
+		if c.status == 0 {
+			if err != nil {
+				t.Errorf("%s: %v", c.name, err)
+			}
+			continue
+		}
+		handshakeErr, ok := err.(*HandshakeError)
+		if !ok || handshakeErr.Status != c.status {
+			t.Errorf("%s: err = %v, want status %d", c.name, err, c.status)
+		}
+	}
+}
+
+func TestAcceptDeflate(t *testing.T) {
+	cases := map[string]bool{
+		"permessage-deflate":                                                true,
+		"permessage-deflate; client_max_window_bits":                        true,
+		"permessage-deflate; client_no_context_takeover":                    true,
+		"permessage-deflate; server_no_context_takeover":                    true,
+		"permessage-deflate; server_max_window_bits=10":                     false,
+		"permessage-deflate; server_max_window_bits=10, permessage-deflate": true,
+		"x-webkit-deflate-frame":                                            false,
+	}
+	for offer, want := range cases {
+		header := http.Header{}
+		header.Set("Sec-WebSocket-Extensions", offer)
+		if got := acceptDeflate(header); got != want {
+			t.Errorf("%q: got %v, want %v", offer, got, want)
+		}
+	}
+}
+
+// 通过真实的连接完成握手，返回握手响应和连接
+func dial(t *testing.T, url string, header http.Header) (*http.Response, net.Conn, *bufio.Reader) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(url, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	r := handshakeRequest()
+	r.URL.Host = conn.RemoteAddr().String()
+	r.Host = r.URL.Host
+	r.RequestURI = ""
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	if err := r.Write(conn); err != nil {
+		t.Fatal(err)
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, conn, reader
+}
+
+func echoServer(t *testing.T, opts *Options) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r, opts)
+		if err != nil {
+			http.Error(w, err.Error(), err.(*HandshakeError).Status)
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, data)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestUpgrade(t *testing.T) {
+	url := echoServer(t, &Options{Subprotocols: []string{"chat", "json"}})
+	resp, conn, reader := dial(t, url, http.Header{"Sec-Websocket-Protocol": {"json, chat"}})
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("status = %d", resp.StatusCode)
+	}
+	// RFC 6455 1.3 中的示例
+	if accept := resp.Header.Get("Sec-WebSocket-Accept"); accept != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("accept = %q", accept)
+	}
+	if protocol := resp.Header.Get("Sec-WebSocket-Protocol"); protocol != "json" {
+		t.Fatalf("subprotocol = %q", protocol)
+	}
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("extensions = %q, compression is disabled", ext)
+	}
+
+	conn.Write(text(true, "hello"))
+	f, err := readServerFrame(reader)
+	if err != nil || f.opcode != TextMessage || f.rsv1 || string(f.payload) != "hello" {
+		t.Fatalf("frame = %+v, err = %v", f, err)
+	}
+}
+
+func TestUpgradeCompression(t *testing.T) {
+	url := echoServer(t, &Options{EnableCompression: true})
+
+	// 无论客户端是否要求，服务端都协商为双方不复用上下文
+	for _, offer := range []string{
+		"permessage-deflate",
+		"permessage-deflate; client_no_context_takeover; server_no_context_takeover",
+	} {
+		resp, conn, reader := dial(t, url, http.Header{"Sec-Websocket-Extensions": {offer}})
+		ext := resp.Header.Get("Sec-WebSocket-Extensions")
+		if !strings.Contains(ext, "permessage-deflate") ||
+			!strings.Contains(ext, "server_no_context_takeover") ||
+			!strings.Contains(ext, "client_no_context_takeover") {
+			t.Fatalf("%q: extensions = %q", offer, ext)
+		}
+
+		message := strings.Repeat("compressed ", 30)
+		for i := 0; i < 2; i++ {
+			payload, _ := compress([]byte(message))
+			conn.Write(clientFrame(true, true, TextMessage, payload, true))
+			f, err := readServerFrame(reader)
+			if err != nil || !f.rsv1 {
+				t.Fatalf("%q: frame = %+v, err = %v", offer, f, err)
+			}
+			data, err := decompress(f.payload, 0)
+			if err != nil || string(data) != message {
+				t.Fatalf("%q: data = %q, err = %v", offer, data, err)
+			}
+		}
+	}
+
+	// 不接受的参数时不启用压缩
+	resp, _, _ := dial(t, url, http.Header{"Sec-Websocket-Extensions": {"permessage-deflate; server_max_window_bits=9"}})
+	if ext := resp.Header.Get("Sec-WebSocket-Extensions"); ext != "" {
+		t.Fatalf("extensions = %q", ext)
+	}
+}
@@ -0,0 +1,89 @@
+package wtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 支持 $.a.b、$.a[0].b 和 $['a'] 形式的路径
+func lookupJSONPath(doc any, path string) (any, error) {
+	if !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("path must start with $")
+	}
+	rest := path[1:]
+	current := doc
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end == -1 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			rest = rest[end+1:]
+
+			object, ok := current.(map[string]any)
+			if !ok {
+				return nil, fmt.Errorf("%q: not an object", key)
+			}
+			if current, ok = object[key]; !ok {
+				return nil, fmt.Errorf("%q: not found", key)
+			}
+		case '[':
+			end := strings.IndexRune(rest, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated [")
+			}
+			token := rest[1:end]
+			rest = rest[end+1:]
+
+			if len(token) >= 2 && (token[0] == '\'' || token[0] == '"') {
+				key := token[1 : len(token)-1]
+				object, ok := current.(map[string]any)
+				if !ok {
+					return nil, fmt.Errorf("%q: not an object", key)
+				}
+				if current, ok = object[key]; !ok {
+					return nil, fmt.Errorf("%q: not found", key)
+				}
+				continue
+			}
+
+			idx, err := strconv.Atoi(token)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q", token)
+			}
+			array, ok := current.([]any)
+			if !ok {
+				return nil, fmt.Errorf("[%d]: not an array", idx)
+			}
+			if idx < 0 {
+				idx += len(array)
+			}
+			if idx < 0 || idx >= len(array) {
+				return nil, fmt.Errorf("[%d]: out of range", idx)
+			}
+			current = array[idx]
+		default:
+			return nil, fmt.Errorf("unexpected %q", rest)
+		}
+	}
+	return current, nil
+}
+
+// 将want转换为JSON的通用表示后比较
+func jsonEqual(got any, want any) bool {
+	data, err := json.Marshal(want)
+	if err != nil {
+		return false
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(got, normalized)
+}
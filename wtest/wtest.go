@@ -0,0 +1,336 @@
+// 进程内测试工具，不经过网络，直接调用http.Handler（通常是*server.HttpServer）
+//
+//	wtest.New(srv).GET("/u/1").Header("X-Token", "t").Expect(t).
+//		Status(200).JSONPath("$.name", "x")
+package wtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// 请求发往的虚拟地址，只用于cookie的作用域
+const BASE_URL = "http://weber.test"
+
+// 测试客户端，多次请求之间共享cookie
+type Client struct {
+	handler http.Handler
+
+	base *url.URL
+
+	jar http.CookieJar
+
+	// 每个请求默认携带的请求头
+	header http.Header
+}
+
+func New(handler http.Handler) *Client {
+	base, _ := url.Parse(BASE_URL)
+	jar, _ := cookiejar.New(nil)
+	return &Client{
+		handler: handler,
+		base:    base,
+		jar:     jar,
+		header:  http.Header{},
+	}
+}
+
+// 设置每个请求默认携带的请求头
+func (c *Client) Header(key string, value string) *Client {
+	c.header.Set(key, value)
+	return c
+}
+
+// 当前保存的cookie
+func (c *Client) Cookies() []*http.Cookie {
+	return c.jar.Cookies(c.base)
+}
+
+func (c *Client) GET(path string) *Request {
+	return c.Request(http.MethodGet, path)
+}
+
+func (c *Client) POST(path string) *Request {
+	return c.Request(http.MethodPost, path)
+}
+
+func (c *Client) PUT(path string) *Request {
+	return c.Request(http.MethodPut, path)
+}
+
+func (c *Client) DELETE(path string) *Request {
+	return c.Request(http.MethodDelete, path)
+}
+
+func (c *Client) PATCH(path string) *Request {
+	return c.Request(http.MethodPatch, path)
+}
+
+func (c *Client) Request(method string, path string) *Request {
+	return &Request{
+		client: c,
+		method: method,
+		path:   path,
+		header: c.header.Clone(),
+		query:  url.Values{},
+	}
+}
+
+// 待发送的请求
+type Request struct {
+	client *Client
+
+	method string
+	path   string
+
+	header http.Header
+	query  url.Values
+
+	body        []byte
+	contentType string
+
+	// multipart表单
+	fields []multipartPart
+
+	// 构造请求时的错误，在Expect时报告
+	err error
+}
+
+type multipartPart struct {
+	name     string
+	filename string
+	content  []byte
+}
+
+func (r *Request) Header(key string, value string) *Request {
+	r.header.Add(key, value)
+	return r
+}
+
+func (r *Request) Query(key string, value string) *Request {
+	r.query.Add(key, value)
+	return r
+}
+
+// 只对当前请求生效的cookie
+func (r *Request) Cookie(cookie *http.Cookie) *Request {
+	r.header.Add("Cookie", cookie.String())
+	return r
+}
+
+// 以JSON格式发送请求体
+func (r *Request) JSON(body any) *Request {
+	data, err := json.Marshal(body)
+	if err != nil {
+		r.err = err
+	}
+	return r.Body("application/json", data)
+}
+
+// 以application/x-www-form-urlencoded格式发送请求体
+func (r *Request) Form(values url.Values) *Request {
+	return r.Body("application/x-www-form-urlencoded", []byte(values.Encode()))
+}
+
+func (r *Request) Body(contentType string, body []byte) *Request {
+	r.contentType = contentType
+	r.body = body
+	return r
+}
+
+// multipart/form-data的普通字段
+func (r *Request) MultipartField(name string, value string) *Request {
+	r.fields = append(r.fields, multipartPart{name: name, content: []byte(value)})
+	return r
+}
+
+// multipart/form-data的文件字段
+func (r *Request) MultipartFile(name string, filename string, content []byte) *Request {
+	r.fields = append(r.fields, multipartPart{name: name, filename: filename, content: content})
+	return r
+}
+
+// 构造http.Request
+func (r *Request) Build() (*http.Request, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	target, err := r.client.base.Parse(r.path)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.query) != 0 {
+		query := target.Query()
+		for key, values := range r.query {
+			query[key] = append(query[key], values...)
+		}
+		target.RawQuery = query.Encode()
+	}
+
+	body, contentType := r.body, r.contentType
+	if len(r.fields) != 0 {
+		if body, contentType, err = r.multipart(); err != nil {
+			return nil, err
+		}
+	}
+
+	request := httptest.NewRequest(r.method, target.String(), bytes.NewReader(body))
+	request.Header = r.header.Clone()
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	for _, cookie := range r.client.jar.Cookies(target) {
+		request.AddCookie(cookie)
+	}
+	return request, nil
+}
+
+func (r *Request) multipart() ([]byte, string, error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+	for _, field := range r.fields {
+		var part io.Writer
+		var err error
+		if field.filename != "" {
+			part, err = writer.CreateFormFile(field.name, field.filename)
+		} else {
+			part, err = writer.CreateFormField(field.name)
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if _, err := part.Write(field.content); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), writer.FormDataContentType(), nil
+}
+
+// 发送请求，响应中的cookie保存到客户端
+func (r *Request) Do() (*httptest.ResponseRecorder, error) {
+	request, err := r.Build()
+	if err != nil {
+		return nil, err
+	}
+	recorder := httptest.NewRecorder()
+	r.client.handler.ServeHTTP(recorder, request)
+
+	if cookies := recorder.Result().Cookies(); len(cookies) != 0 {
+		r.client.jar.SetCookies(request.URL, cookies)
+	}
+	return recorder, nil
+}
+
+// 发送请求并返回用于断言的响应，构造请求失败时立即终止测试
+func (r *Request) Expect(t testing.TB) *Response {
+	t.Helper()
+	recorder, err := r.Do()
+	if err != nil {
+		t.Fatalf("wtest: %s %s: %v", r.method, r.path, err)
+	}
+	return &Response{t: t, desc: fmt.Sprintf("%s %s", r.method, r.path), Recorder: recorder}
+}
+
+// 断言失败时调用t.Errorf，可以继续链式断言
+type Response struct {
+	t    testing.TB
+	desc string
+
+	Recorder *httptest.ResponseRecorder
+}
+
+func (r *Response) StatusCode() int {
+	return r.Recorder.Code
+}
+
+func (r *Response) BodyBytes() []byte {
+	return r.Recorder.Body.Bytes()
+}
+
+func (r *Response) Status(code int) *Response {
+	r.t.Helper()
+	if r.Recorder.Code != code {
+		r.t.Errorf("wtest: %s: status = %d, want %d", r.desc, r.Recorder.Code, code)
+	}
+	return r
+}
+
+func (r *Response) Header(key string, value string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Header().Get(key); got != value {
+		r.t.Errorf("wtest: %s: header %s = %q, want %q", r.desc, key, got, value)
+	}
+	return r
+}
+
+func (r *Response) Body(body string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); got != body {
+		r.t.Errorf("wtest: %s: body = %q, want %q", r.desc, got, body)
+	}
+	return r
+}
+
+func (r *Response) BodyContains(substr string) *Response {
+	r.t.Helper()
+	if got := r.Recorder.Body.String(); !strings.Contains(got, substr) {
+		r.t.Errorf("wtest: %s: body = %q, want it to contain %q", r.desc, got, substr)
+	}
+	return r
+}
+
+func (r *Response) Cookie(name string, value string) *Response {
+	r.t.Helper()
+	for _, cookie := range r.Recorder.Result().Cookies() {
+		if cookie.Name == name {
+			if cookie.Value != value {
+				r.t.Errorf("wtest: %s: cookie %s = %q, want %q", r.desc, name, cookie.Value, value)
+			}
+			return r
+		}
+	}
+	r.t.Errorf("wtest: %s: cookie %s not set", r.desc, name)
+	return r
+}
+
+// 将响应体解析到dest
+func (r *Response) JSON(dest any) *Response {
+	r.t.Helper()
+	if err := json.Unmarshal(r.BodyBytes(), dest); err != nil {
+		r.t.Errorf("wtest: %s: invalid JSON body: %v", r.desc, err)
+	}
+	return r
+}
+
+// 断言JSON响应体中path处的值，例如 $.users[0].name
+// want按JSON语义比较，数字1与1.0相等
+func (r *Response) JSONPath(path string, want any) *Response {
+	r.t.Helper()
+	var doc any
+	if err := json.Unmarshal(r.BodyBytes(), &doc); err != nil {
+		r.t.Errorf("wtest: %s: invalid JSON body: %v", r.desc, err)
+		return r
+	}
+	got, err := lookupJSONPath(doc, path)
+	if err != nil {
+		r.t.Errorf("wtest: %s: %s: %v", r.desc, path, err)
+		return r
+	}
+	if !jsonEqual(got, want) {
+		r.t.Errorf("wtest: %s: %s = %v, want %v", r.desc, path, got, want)
+	}
+	return r
+}
@@ -0,0 +1,243 @@
+package wtest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/asxlwsl/weber/server"
+	"github.com/asxlwsl/weber/wcontext"
+)
+
+func TestLookupJSONPath(t *testing.T) {
+	var doc any
+	json.Unmarshal([]byte(`{"user":{"name":"weber","tags":["a","b","c"]},"items":[{"id":1},{"id":2}],"a.b":true}`), &doc)
+
+	cases := []struct {
+		path string
+		want any
+		err  bool
+	}{
+		{path: "$", want: doc},
+		{path: "$.user.name", want: "weber"},
+		{path: "$.user.tags[1]", want: "b"},
+		{path: "$.user.tags[-1]", want: "c"},
+		{path: "$.items[1].id", want: float64(2)},
+		{path: "$['a.b']", want: true},
+		{path: `$.user["name"]`, want: "weber"},
+		{path: "$.missing", err: true},
+		{path: "$.user.tags[3]", err: true},
+		{path: "$.user.name[0]", err: true},
+		{path: "$.items.id", err: true},
+		{path: "$.items[x]", err: true},
+		{path: "$.items[0", err: true},
+		{path: "user", err: true},
+	}
+	for _, c := range cases {
+		got, err := lookupJSONPath(doc, c.path)
+		if c.err {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", c.path, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", c.path, err)
+			continue
+		}
+		if !jsonEqual(got, c.want) {
+			t.Errorf("%s = %v, want %v", c.path, got, c.want)
+		}
+	}
+}
+
+func TestJSONEqual(t *testing.T) {
+	if !jsonEqual(float64(1), 1) {
+		t.Error("1.0 should equal 1")
+	}
+	if !jsonEqual([]any{"a", float64(2)}, []any{"a", 2}) {
+		t.Error("arrays should be compared by JSON value")
+	}
+	if jsonEqual("1", 1) {
+		t.Error(`"1" should not equal 1`)
+	}
+}
+
+func newServer() *server.HttpServer {
+	srv := server.NewHttpServer()
+	srv.GET("/users/:id", func(ctx *wcontext.Context) {
+		id, _ := ctx.GetParam("id")
+		ctx.JSON(wcontext.H{"id": id, "roles": []string{"admin", "dev"}, "agent": ctx.Request().Header.Get("User-Agent")})
+	})
+	srv.POST("/login", func(ctx *wcontext.Context) {
+		http.SetCookie(ctx.Writer(), &http.Cookie{Name: "session", Value: "s-1", Path: "/"})
+		ctx.TEXT("ok")
+	})
+	srv.GET("/me", func(ctx *wcontext.Context) {
+		cookie, err := ctx.Request().Cookie("session")
+		if err != nil {
+			ctx.Fail(http.StatusUnauthorized, "401 Unauthorized")
+			return
+		}
+		ctx.TEXT(cookie.Value)
+	})
+	srv.POST("/upload", func(ctx *wcontext.Context) {
+		file, header, err := ctx.Request().FormFile("file")
+		if err != nil {
+			ctx.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		defer file.Close()
+		content, _ := io.ReadAll(file)
+		ctx.JSON(wcontext.H{
+			"title":    ctx.Request().FormValue("title"),
+			"filename": header.Filename,
+			"content":  string(content),
+		})
+	})
+	srv.POST("/form", func(ctx *wcontext.Context) {
+		name, _ := ctx.GetForm("name")
+		ctx.TEXT(name + "," + ctx.Request().URL.Query().Get("page"))
+	})
+	srv.POST("/echo", func(ctx *wcontext.Context) {
+		var body map[string]any
+		if err := ctx.BindJSON(&body); err != nil {
+			ctx.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		ctx.JSON(body)
+	})
+	return srv
+}
+
+func TestJSONResponse(t *testing.T) {
+	client := New(newServer()).Header("User-Agent", "wtest")
+
+	client.GET("/users/7").Expect(t).
+		Status(http.StatusOK).
+		Header("Content-Type", "application/json;").
+		JSONPath("$.id", "7").
+		JSONPath("$.roles[0]", "admin").
+		JSONPath("$.roles", []string{"admin", "dev"}).
+		JSONPath("$.agent", "wtest")
+
+	var echoed struct {
+		Count int `json:"count"`
+	}
+	client.POST("/echo").JSON(map[string]int{"count": 3}).Expect(t).
+		Status(http.StatusOK).
+		JSON(&echoed)
+	if echoed.Count != 3 {
+		t.Errorf("count = %d, want 3", echoed.Count)
+	}
+}
+
+func TestCookieJar(t *testing.T) {
+	client := New(newServer())
+
+	client.GET("/me").Expect(t).Status(http.StatusUnauthorized)
+	client.POST("/login").Expect(t).Status(http.StatusOK).Cookie("session", "s-1")
+
+	// 登录返回的cookie保存在客户端中，之后的请求自动携带
+	client.GET("/me").Expect(t).Status(http.StatusOK).Body("s-1")
+	if cookies := client.Cookies(); len(cookies) != 1 || cookies[0].Value != "s-1" {
+		t.Errorf("cookies = %v", cookies)
+	}
+
+	// 独立的客户端之间不共享cookie，单个请求可以额外携带cookie
+	New(newServer()).GET("/me").Expect(t).Status(http.StatusUnauthorized)
+	New(newServer()).GET("/me").Cookie(&http.Cookie{Name: "session", Value: "s-2"}).Expect(t).Body("s-2")
+}
+
+func TestMultipart(t *testing.T) {
+	client := New(newServer())
+
+	client.POST("/upload").
+		MultipartField("title", "report").
+		MultipartFile("file", "report.txt", []byte("hello")).
+		Expect(t).
+		Status(http.StatusOK).
+		JSONPath("$.title", "report").
+		JSONPath("$.filename", "report.txt").
+		JSONPath("$.content", "hello")
+
+	req, err := client.POST("/upload").MultipartFile("file", "a.txt", []byte("x")).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := req.FormFile("file"); err != nil {
+		t.Errorf("built request has no file: %v", err)
+	}
+}
+
+func TestFormAndQuery(t *testing.T) {
+	New(newServer()).POST("/form").
+		Query("page", "2").
+		Form(url.Values{"name": {"weber"}}).
+		Expect(t).
+		Status(http.StatusOK).
+		Body("weber,2")
+}
+
+func TestNotFound(t *testing.T) {
+	New(newServer()).GET("/nothing").Expect(t).Status(http.StatusNotFound)
+}
+
+// 记录经过的中间件，视图函数把记录作为响应体返回
+func trace(name string) server.MiddlewareHandleFunc {
+	return func(next wcontext.HandleFunc) wcontext.HandleFunc {
+		return func(ctx *wcontext.Context) {
+			names, _ := ctx.Get("trace")
+			trail, _ := names.(string)
+			ctx.Set("trace", trail+name+",")
+			next(ctx)
+		}
+	}
+}
+
+func traced(ctx *wcontext.Context) {
+	names, _ := ctx.Get("trace")
+	trail, _ := names.(string)
+	ctx.TEXT(trail)
+}
+
+func TestGroupMiddlewares(t *testing.T) {
+	srv := server.NewHttpServer()
+	srv.RouterGroup.Use(trace("root"))
+
+	v1 := srv.Group("/v1")
+	v1.Use(trace("v1"))
+	v1.GET("/users", traced)
+
+	admin := v1.Group("/admin")
+	admin.Use(trace("admin"))
+	admin.GET("/stats", traced)
+
+	srv.Group("/v10").GET("/users", traced)
+	srv.GET("/health", traced)
+
+	client := New(srv)
+	// 前缀相同但不是同一个路由组，/v1的中间件不作用于/v10
+	client.GET("/v10/users").Expect(t).Status(http.StatusOK).Body("root,")
+	client.GET("/v1/users").Expect(t).Status(http.StatusOK).Body("root,v1,")
+	// 外层路由组的中间件先于内层执行
+	client.GET("/v1/admin/stats").Expect(t).Status(http.StatusOK).Body("root,v1,admin,")
+	client.GET("/health").Expect(t).Status(http.StatusOK).Body("root,")
+}
+
+func TestRootGroupMiddlewares(t *testing.T) {
+	srv := server.NewHttpServer()
+	srv.GET("/", traced)
+	srv.Group("/api").GET("/items", traced)
+
+	// 根路由组的中间件作用于所有路由，包括之前注册的路由
+	srv.RouterGroup.Use(trace("root"))
+	srv.Group("/api").Group("/v2").GET("/items", traced)
+
+	client := New(srv)
+	client.GET("/").Expect(t).Status(http.StatusOK).Body("root,")
+	client.GET("/api/items").Expect(t).Status(http.StatusOK).Body("root,")
+	client.GET("/api/v2/items").Expect(t).Status(http.StatusOK).Body("root,")
+}